@@ -3,10 +3,12 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"text/tabwriter"
 
 	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/env"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -24,7 +26,10 @@ var envListCmd = &cobra.Command{
 	Use:     "list <project>",
 	Aliases: []string{"ls"},
 	Short:   "List environments for a project",
-	Args:    cobra.ExactArgs(1),
+	Long: `Lists the environments of a project as an inheritance tree, with their
+files and overrides. Override values of secret keys are masked unless
+--reveal is given.`,
+	Args: cobra.ExactArgs(1),
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) != 0 {
 			return nil, cobra.ShellCompDirectiveNoFileComp
@@ -45,26 +50,110 @@ var envListCmd = &cobra.Command{
 			return nil
 		}
 
+		reveal, _ := cmd.Flags().GetBool("reveal")
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
 		bold := color.New(color.Bold)
 		bold.Fprintf(w, "ENV\tFILES\tOVERRIDES\n")
-		for name, e := range project.Envs {
-			overrides := make([]string, 0, len(e.Overrides))
-			for k, v := range e.Overrides {
-				overrides = append(overrides, k+"="+v)
+		for _, row := range envTree(project.Envs) {
+			e := project.Envs[row.name]
+			// Secret patterns come from every layer; an env whose layers
+			// cannot be resolved still has its own.
+			secrets := e.Secrets
+			if settings, err := envSettings(cfg, project, row.name); err == nil {
+				secrets = settings.Secrets
+			}
+			keys := make([]string, 0, len(e.Overrides))
+			for k := range e.Overrides {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			overrides := make([]string, len(keys))
+			for i, k := range keys {
+				v := e.Overrides[k]
+				if !reveal {
+					v = env.Mask(k, v, secrets)
+				}
+				overrides[i] = k + "=" + displayValue(v)
+			}
+			name := row.prefix + row.name
+			// The tree shows the first parent; name the others, and any
+			// parent of an env listed at the top level.
+			if len(e.Extends) > 1 || (row.prefix == "" && len(e.Extends) > 0) {
+				name += " (extends " + strings.Join(e.Extends, ", ") + ")"
 			}
-			fmt.Fprintf(w, "%s\t%s\t%s\n", name, strings.Join(e.Files, ", "), strings.Join(overrides, ", "))
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, strings.Join(config.FilePaths(e.Files), ", "), strings.Join(overrides, ", "))
 		}
 		w.Flush()
 		return nil
 	},
 }
 
+type envTreeRow struct {
+	name   string
+	prefix string
+}
+
+// envTree orders envs as an inheritance tree: each env is listed under the
+// first env it extends, with box-drawing prefixes showing the nesting.
+func envTree(envs map[string]config.Env) []envTreeRow {
+	children := make(map[string][]string)
+	var roots []string
+	for name, e := range envs {
+		if len(e.Extends) > 0 {
+			if _, ok := envs[e.Extends[0]]; ok {
+				children[e.Extends[0]] = append(children[e.Extends[0]], name)
+				continue
+			}
+		}
+		roots = append(roots, name)
+	}
+	sort.Strings(roots)
+
+	var rows []envTreeRow
+	seen := make(map[string]bool)
+	var walk func(name, prefix, indent string)
+	walk = func(name, prefix, indent string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		rows = append(rows, envTreeRow{name: name, prefix: prefix})
+
+		kids := children[name]
+		sort.Strings(kids)
+		for i, kid := range kids {
+			if i == len(kids)-1 {
+				walk(kid, indent+"└─ ", indent+"   ")
+			} else {
+				walk(kid, indent+"├─ ", indent+"│  ")
+			}
+		}
+	}
+	for _, r := range roots {
+		walk(r, "", "")
+	}
+
+	// Envs caught in an extends cycle, and those extending them, have no
+	// root; list them flat.
+	var rest []string
+	for name := range envs {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, r := range rest {
+		rows = append(rows, envTreeRow{name: r})
+	}
+	return rows
+}
+
 // --- env add ---
 
 var (
 	envAddFiles     []string
 	envAddOverrides []string
+	envAddExtends   []string
 )
 
 var envAddCmd = &cobra.Command{
@@ -104,8 +193,20 @@ var envAddCmd = &cobra.Command{
 			overrides[parts[0]] = parts[1]
 		}
 
+		files := make([]config.FileRef, len(envAddFiles))
+		for i, f := range envAddFiles {
+			files[i] = config.FileRef{Path: f}
+		}
+
+		for _, parent := range envAddExtends {
+			if _, exists := project.Envs[parent]; !exists {
+				return fmt.Errorf("environment %q not found in project %q", parent, projectName)
+			}
+		}
+
 		project.Envs[envName] = config.Env{
-			Files:     envAddFiles,
+			Extends:   envAddExtends,
+			Files:     files,
 			Overrides: overrides,
 		}
 		cfg.Projects[projectName] = project
@@ -149,6 +250,14 @@ var envRemoveCmd = &cobra.Command{
 			return fmt.Errorf("environment %q not found in project %q", envName, projectName)
 		}
 
+		for name, e := range project.Envs {
+			for _, parent := range e.Extends {
+				if parent == envName {
+					return fmt.Errorf("environment %q is extended by %q; remove or change that first", envName, name)
+				}
+			}
+		}
+
 		delete(project.Envs, envName)
 		cfg.Projects[projectName] = project
 
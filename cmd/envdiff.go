@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/env"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	envDiffAgainstOS bool
+	envDiffJSON      bool
+	envDiffReveal    bool
+)
+
+var envDiffCmd = &cobra.Command{
+	Use:   "diff [project] <envA> <envB>",
+	Short: "Show how two environments differ",
+	Long: `Resolves two environments and prints the keys that the second one adds,
+removes or changes compared to the first. Secret values are masked unless
+--reveal is given.
+
+Either env can be written as project:env to compare envs of different
+projects. With --against-os, a single env is compared with the current
+shell: the result is what 'menv run' would change in the environment,
+including variables dropped by pure mode or unset.
+If you are inside a project directory, the project name can be omitted.
+
+Examples:
+  menv env diff my-app staging prod
+  menv env diff staging prod                  # auto-detect project from CWD
+  menv env diff api:prod worker:prod          # same env, two projects
+  menv env diff dev --against-os
+  menv env diff my-app staging prod --json`,
+	Args:              cobra.RangeArgs(1, 3),
+	ValidArgsFunction: completeEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+
+		want := 2
+		if envDiffAgainstOS {
+			want = 1
+		}
+
+		// The first env, with its optional project, is resolved like any
+		// other command's; later envs default to the same project.
+		var sides []diffSide
+		projectName, specs := "", args
+		if !strings.Contains(args[0], ":") {
+			name, project, envName, rest, err := resolveEnvArgs(cfg, args)
+			if err != nil {
+				return err
+			}
+			if len(rest) != want-1 {
+				return diffArgsError(len(args))
+			}
+			side, err := loadDiffSide(cfg, name, project, envName)
+			if err != nil {
+				return err
+			}
+			sides, projectName, specs = append(sides, side), name, rest
+		} else if len(specs) != want {
+			return diffArgsError(len(args))
+		}
+		for _, spec := range specs {
+			name, envName := projectName, spec
+			if p, e, ok := strings.Cut(spec, ":"); ok {
+				name, envName = p, e
+			}
+			name, project, err := resolveProject(cfg, name)
+			if err != nil {
+				return err
+			}
+			if _, exists := project.Envs[envName]; !exists {
+				return fmt.Errorf("environment %q not found in project %q", envName, name)
+			}
+			side, err := loadDiffSide(cfg, name, project, envName)
+			if err != nil {
+				return err
+			}
+			sides = append(sides, side)
+		}
+
+		var from, to diffSide
+		if envDiffAgainstOS {
+			s := sides[0]
+			from = diffSide{label: "os", values: env.BaseEnv(env.BuildOptions{}), secrets: s.secrets}
+			to = s
+			to.values = env.BaseEnv(s.opts)
+			for k, v := range s.vars {
+				to.values[k] = v
+			}
+		} else {
+			from, to = sides[0], sides[1]
+			if from.project != to.project {
+				from.label = from.project + ":" + from.label
+				to.label = to.project + ":" + to.label
+			}
+		}
+
+		secrets := append(append([]string{}, from.secrets...), to.secrets...)
+		display := func(key, value string) string {
+			if !envDiffReveal {
+				value = env.Mask(key, value, secrets)
+			}
+			return value
+		}
+
+		changes := env.Diff(from.values, to.values)
+
+		if envDiffJSON {
+			return printDiffJSON(from.label, to.label, changes, display)
+		}
+
+		color.Cyan("» diff: %s → %s", from.label, to.label)
+		if len(changes) == 0 {
+			color.Green("✓ No differences.")
+			return nil
+		}
+
+		counts := make(map[env.ChangeKind]int)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		bold := color.New(color.Bold)
+		bold.Fprintf(w, " \tKEY\t%s\t%s\n", strings.ToUpper(from.label), strings.ToUpper(to.label))
+		for _, c := range changes {
+			counts[c.Kind]++
+			var sign string
+			switch c.Kind {
+			case env.Added:
+				sign = color.GreenString("+")
+			case env.Removed:
+				sign = color.RedString("-")
+			default:
+				sign = color.YellowString("~")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", sign, c.Key, displayValue(display(c.Key, c.Old)), displayValue(display(c.Key, c.New)))
+		}
+		w.Flush()
+		color.HiBlack("%d added, %d removed, %d changed", counts[env.Added], counts[env.Removed], counts[env.Changed])
+		return nil
+	},
+}
+
+// diffArgsError reports a wrong number of envs given to env diff.
+func diffArgsError(n int) error {
+	if envDiffAgainstOS {
+		return fmt.Errorf("expected [project] <env> with --against-os, got %d argument(s)", n)
+	}
+	return fmt.Errorf("expected [project] <envA> <envB>, got %d argument(s)", n)
+}
+
+// diffSide is one side of a diff.
+type diffSide struct {
+	project string
+	label   string
+	values  map[string]string
+	secrets []string
+	// vars and opts are kept to build the full environment for --against-os.
+	vars map[string]string
+	opts env.BuildOptions
+}
+
+// loadDiffSide loads envName of project for one side of a diff.
+func loadDiffSide(cfg *config.Config, projectName string, project config.Project, envName string) (diffSide, error) {
+	settings, err := envSettings(cfg, project, envName)
+	if err != nil {
+		return diffSide{}, err
+	}
+	opts := buildOptions(settings, false)
+	loaded, err := env.LoadEnv(cfg, project, envName, opts)
+	if err != nil {
+		return diffSide{}, fmt.Errorf("%s:%s: %w", projectName, envName, err)
+	}
+
+	return diffSide{
+		project: projectName,
+		label:   envName,
+		values:  loaded.Values(),
+		secrets: settings.Secrets,
+		vars:    loaded.Values(),
+		opts:    opts,
+	}, nil
+}
+
+// printDiffJSON writes changes as a JSON document for scripts and CI.
+func printDiffJSON(from, to string, changes []env.Change, display func(key, value string) string) error {
+	type jsonChange struct {
+		Key  string  `json:"key"`
+		Kind string  `json:"kind"`
+		Old  *string `json:"old,omitempty"`
+		New  *string `json:"new,omitempty"`
+	}
+	out := struct {
+		From    string       `json:"from"`
+		To      string       `json:"to"`
+		Changes []jsonChange `json:"changes"`
+	}{From: from, To: to, Changes: []jsonChange{}}
+
+	for _, c := range changes {
+		jc := jsonChange{Key: c.Key, Kind: string(c.Kind)}
+		if c.Kind != env.Added {
+			old := display(c.Key, c.Old)
+			jc.Old = &old
+		}
+		if c.Kind != env.Removed {
+			v := display(c.Key, c.New)
+			jc.New = &v
+		}
+		out.Changes = append(out.Changes, jc)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func init() {
+	envDiffCmd.Flags().BoolVar(&envDiffAgainstOS, "against-os", false, "compare a single env with the current shell environment")
+	envDiffCmd.Flags().BoolVar(&envDiffJSON, "json", false, "output the differences as JSON")
+	envDiffCmd.Flags().BoolVar(&envDiffReveal, "reveal", false, "show secret values instead of masking them")
+
+	envCmd.AddCommand(envDiffCmd)
+}
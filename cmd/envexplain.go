@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/akpatel363/menv/internal/env"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var envExplainCmd = &cobra.Command{
+	Use:   "explain [project] <env> <key>",
+	Short: "Show where a variable's value comes from",
+	Long: `Prints the full precedence chain for a single variable: the value that
+wins, followed by every definition it shadows, down to the OS environment.
+If you are inside a project directory, the project name can be omitted.
+
+Examples:
+  menv env explain my-app prod DATABASE_URL
+  menv env explain prod DATABASE_URL        # auto-detect project from CWD`,
+	Args:              cobra.RangeArgs(2, 3),
+	ValidArgsFunction: completeEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+
+		projectName, project, envName, keys, err := resolveEnvArgs(cfg, args)
+		if err != nil {
+			return err
+		}
+		if len(keys) != 1 {
+			return fmt.Errorf("expected [project] <env> <key>, got %d argument(s)", len(args))
+		}
+		key := keys[0]
+
+		settings, err := envSettings(cfg, project, envName)
+		if err != nil {
+			return err
+		}
+		opts := buildOptions(settings, false)
+		loaded, err := env.LoadEnv(cfg, project, envName, opts)
+		if err != nil {
+			return err
+		}
+
+		color.Cyan("» project: %s | env: %s | key: %s", projectName, envName, key)
+
+		v, ok := loaded[key]
+		if !ok {
+			if osValue, set := env.BaseEnv(opts)[key]; set {
+				v = env.Var{Value: osValue, Source: env.Source{Kind: env.SourceOS}}
+			} else {
+				color.Yellow("%s is not set by this environment or passed on from the OS", key)
+				return nil
+			}
+		}
+
+		reveal, _ := cmd.Flags().GetBool("reveal")
+		display := func(value string) string {
+			if !reveal {
+				value = env.Mask(key, value, settings.Secrets)
+			}
+			return displayValue(value)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		bold := color.New(color.Bold)
+		bold.Fprintf(w, "#\tVALUE\tSOURCE\t\n")
+		fmt.Fprintf(w, "1\t%s\t%s\t%s\n", display(v.Value), v.Source, color.GreenString("(effective)"))
+		for i, s := range v.Shadowed {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+2, display(s.Value), s.Source, color.HiBlackString("(shadowed)"))
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+func init() {
+	envExplainCmd.Flags().Bool("reveal", false, "show secret values instead of masking them")
+
+	envCmd.AddCommand(envExplainCmd)
+}
@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/env"
+	"github.com/akpatel363/menv/internal/k8s"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// --- env export ---
+
+var (
+	envExportK8s       bool
+	envExportName      string
+	envExportNamespace string
+	envExportLabels    []string
+)
+
+var envExportCmd = &cobra.Command{
+	Use:   "export [project] <env> --k8s",
+	Short: "Export an env as Kubernetes ConfigMap and Secret manifests",
+	Long: `Resolves an environment and prints it as Kubernetes manifests: a
+ConfigMap holding the plain variables and a Secret (type Opaque, values
+base64-encoded under data) holding the ones marked secret, either by the
+default patterns or the env's secrets list. An object with no variables
+is left out.
+
+The objects are named <project>-<env> unless --name is given.
+If you are inside a project directory, the project name can be omitted.
+
+Examples:
+  menv env export my-app prod --k8s
+  menv env export prod --k8s --namespace web | kubectl apply -f -
+  menv env export prod --k8s --name api-config -l app=api -l tier=backend`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !envExportK8s {
+			return fmt.Errorf("choose an export target (--k8s)")
+		}
+
+		cfg := loadConfig()
+		projectName, project, envName, rest, err := resolveEnvArgs(cfg, args)
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
+		}
+
+		settings, err := envSettings(cfg, project, envName)
+		if err != nil {
+			return err
+		}
+		loaded, err := loadValidEnv(cfg, project, envName, buildOptions(settings, false))
+		if err != nil {
+			return err
+		}
+
+		labels := make(map[string]string)
+		for _, l := range envExportLabels {
+			k, v, ok := strings.Cut(l, "=")
+			if !ok || k == "" {
+				return fmt.Errorf("invalid label %q (expected KEY=VALUE)", l)
+			}
+			labels[k] = v
+		}
+
+		name := envExportName
+		if name == "" {
+			name = k8s.Name(projectName + "-" + envName)
+		}
+
+		plain := make(map[string]string)
+		secret := make(map[string]string)
+		for k, v := range loaded {
+			if env.IsSecret(k, settings.Secrets) {
+				secret[k] = v.Value
+			} else {
+				plain[k] = v.Value
+			}
+		}
+
+		opts := k8s.Options{Name: name, Namespace: envExportNamespace, Labels: labels}
+		if err := k8s.Write(os.Stdout, opts, plain, secret); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(secret))
+		for k := range secret {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		warnSecretsOnTerminal(keys, settings.Secrets)
+		return nil
+	},
+}
+
+// --- env import ---
+
+var (
+	envImportK8s   string
+	envImportFile  string
+	envImportForce bool
+)
+
+var envImportCmd = &cobra.Command{
+	Use:   "import [project] [env] --k8s <manifest>",
+	Short: "Create an env from Kubernetes ConfigMap and Secret manifests",
+	Long: `Reads ConfigMaps and Secrets from a YAML manifest (use - for stdin),
+writes their data to a new dotenv file and adds an env that loads it.
+Secret values are base64-decoded; keys that came from a Secret and do not
+match the default secret patterns are added to the env's secrets list, so
+they stay masked and are exported back into a Secret.
+
+The env name defaults to the name of the first object, without a leading
+"<project>-"; the file defaults to .env.<env> in the project directory.
+Keys that are not valid variable names, such as config file names, are
+skipped with a warning.
+If you are inside a project directory, the project name can be omitted.
+
+Examples:
+  menv env import --k8s manifest.yaml                 # env named after the objects
+  menv env import my-app staging --k8s manifest.yaml
+  kubectl get cm,secret -l app=api -o yaml | menv env import prod --k8s -`,
+	Args: cobra.MaximumNArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return getProjectNames(), cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if envImportK8s == "" {
+			return fmt.Errorf("choose a manifest to import (--k8s <file>)")
+		}
+
+		cfg := loadConfig()
+		var projectName, envName string
+		switch len(args) {
+		case 2:
+			projectName, envName = args[0], args[1]
+		case 1:
+			// A single argument is the project if one has that name.
+			if _, ok := cfg.Projects[args[0]]; ok {
+				projectName = args[0]
+			} else {
+				envName = args[0]
+			}
+		}
+		projectName, project, err := resolveProject(cfg, projectName)
+		if err != nil {
+			return err
+		}
+
+		var data []byte
+		if envImportK8s == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(envImportK8s)
+		}
+		if err != nil {
+			return err
+		}
+		manifest, err := k8s.Read(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", envImportK8s, err)
+		}
+		for _, w := range manifest.Warnings {
+			color.Yellow("warning: %s", w)
+		}
+
+		if envName == "" {
+			envName = strings.TrimPrefix(manifest.Name, k8s.Name(projectName)+"-")
+			if envName == "" {
+				return fmt.Errorf("the manifest has no object name; give the env name as an argument")
+			}
+		}
+		if _, exists := project.Envs[envName]; exists {
+			return fmt.Errorf("environment %q already exists in project %q", envName, projectName)
+		}
+
+		values := make(map[string]string)
+		var secrets []string
+		for _, m := range []map[string]string{manifest.Plain, manifest.Secret} {
+			for k, v := range m {
+				if !env.IsIdentifier(k) {
+					color.Yellow("warning: skipped %s: not a valid variable name", k)
+					continue
+				}
+				values[k] = v
+			}
+		}
+		for k := range manifest.Secret {
+			if _, ok := values[k]; ok && !env.IsSecret(k, nil) {
+				secrets = append(secrets, k)
+			}
+			if _, ok := manifest.Plain[k]; ok {
+				color.Yellow("warning: %s is in both a ConfigMap and a Secret; using the Secret", k)
+			}
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("%s: no keys with valid variable names", envImportK8s)
+		}
+		sort.Strings(secrets)
+
+		file := envImportFile
+		if file == "" {
+			file = ".env." + envName
+		}
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(project.Path, path)
+		}
+		if _, err := os.Stat(path); err == nil && !envImportForce {
+			return fmt.Errorf("%s already exists (use --force to overwrite it)", path)
+		}
+
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var buf bytes.Buffer
+		if err := env.Export(&buf, "dotenv", keys, values); err != nil {
+			return err
+		}
+		mode := os.FileMode(0o644)
+		if len(manifest.Secret) > 0 {
+			mode = 0o600
+			// WriteFile keeps the mode of a file it overwrites, so restrict
+			// an existing file before the secrets go into it.
+			if err := os.Chmod(path, mode); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		if err := os.WriteFile(path, buf.Bytes(), mode); err != nil {
+			return err
+		}
+
+		if project.Envs == nil {
+			project.Envs = make(map[string]config.Env)
+		}
+		project.Envs[envName] = config.Env{
+			Files:   []config.FileRef{{Path: file}},
+			Secrets: secrets,
+		}
+		cfg.Projects[projectName] = project
+		if err := config.Save(cfg); err != nil {
+			return err
+		}
+
+		color.Green("✓ Wrote %d variable(s) to %s.", len(keys), file)
+		color.Green("✓ Environment %q added to project %q.", envName, projectName)
+		return nil
+	},
+}
+
+func init() {
+	envExportCmd.Flags().BoolVar(&envExportK8s, "k8s", false, "output a Kubernetes ConfigMap and Secret")
+	envExportCmd.Flags().StringVar(&envExportName, "name", "", "name of the objects (default <project>-<env>)")
+	envExportCmd.Flags().StringVarP(&envExportNamespace, "namespace", "n", "", "namespace of the objects")
+	envExportCmd.Flags().StringSliceVarP(&envExportLabels, "label", "l", nil, "labels as KEY=VALUE (comma-separated or repeated)")
+
+	envImportCmd.Flags().StringVar(&envImportK8s, "k8s", "", "Kubernetes manifest with ConfigMaps and Secrets (- for stdin)")
+	envImportCmd.Flags().StringVarP(&envImportFile, "file", "f", "", "dotenv file to write, relative to the project path (default .env.<env>)")
+	envImportCmd.Flags().BoolVar(&envImportForce, "force", false, "overwrite the dotenv file if it exists")
+
+	envCmd.AddCommand(envExportCmd)
+	envCmd.AddCommand(envImportCmd)
+}
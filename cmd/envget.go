@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/akpatel363/menv/internal/config"
 	"github.com/akpatel363/menv/internal/env"
 
 	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
@@ -29,81 +31,105 @@ Examples:
   menv env get my-app dev                # print all vars
   menv env get dev                       # auto-detect project from CWD
   menv env get dev DB_HOST API_KEY       # print specific vars
-  menv env get my-app dev DB_HOST        # print specific var`,
-	Args: cobra.MinimumNArgs(1),
-	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		switch len(args) {
-		case 0:
-			suggestions := getProjectNames()
-			cfg, err := config.Load()
-			if err == nil {
-				if detected, _ := config.DetectProject(cfg); detected != "" {
-					suggestions = append(suggestions, getEnvNames(detected)...)
-				}
-			}
-			return suggestions, cobra.ShellCompDirectiveNoFileComp
-		case 1:
-			return getEnvNames(args[0]), cobra.ShellCompDirectiveNoFileComp
-		default:
-			return nil, cobra.ShellCompDirectiveNoFileComp
-		}
-	},
+  menv env get my-app dev DB_HOST        # print specific var
+  menv env get dev --format json         # json, yaml, dotenv, docker, systemd, github or posix
+  menv env get prod --format github >> "$GITHUB_ENV"
+  menv env get dev --shell fish | source # load into the current shell
+  menv env get dev --unload | source     # and restore it (shell from $SHELL)`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeEnvArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := loadConfig()
 
-		var projectName, envName string
-		var project config.Project
-		var keys []string
-
-		// Determine if first arg is a project name or an env name.
-		_, isProject := cfg.Projects[args[0]]
+		projectName, project, envName, keys, err := resolveEnvArgs(cfg, args)
+		if err != nil {
+			return err
+		}
 
-		if isProject && len(args) >= 2 {
-			projectName = args[0]
-			envName = args[1]
-			keys = args[2:]
-			var err error
-			_, project, err = resolveProject(cfg, projectName)
-			if err != nil {
-				return err
-			}
-		} else {
-			// First arg is env name — detect project from CWD.
-			envName = args[0]
-			keys = args[1:]
-			var err error
-			projectName, project, err = resolveProject(cfg, "")
+		// Unloading only needs the snapshot, not the env itself.
+		if unload, _ := cmd.Flags().GetBool("unload"); unload {
+			shell, _ := cmd.Flags().GetString("shell")
+			script, err := env.ShellUnloadScript(shell)
 			if err != nil {
 				return err
 			}
+			fmt.Fprint(os.Stdout, script)
+			return nil
 		}
 
-		envCfg, exists := project.Envs[envName]
-		if !exists {
-			return fmt.Errorf("environment %q not found in project %q", envName, projectName)
+		settings, err := envSettings(cfg, project, envName)
+		if err != nil {
+			return err
 		}
-
-		loaded, err := env.LoadEnv(project, envCfg)
+		loaded, err := loadValidEnv(cfg, project, envName, buildOptions(settings, false))
 		if err != nil {
 			return err
 		}
 
-		exportFormat, _ := cmd.Flags().GetBool("export")
+		format, _ := cmd.Flags().GetString("format")
+		if export, _ := cmd.Flags().GetBool("export"); export && format == "" {
+			format = "posix"
+		}
+		reveal, _ := cmd.Flags().GetBool("reveal")
+
+		shell, _ := cmd.Flags().GetString("shell")
+		if cmd.Flags().Changed("shell") {
+			if format != "" {
+				return fmt.Errorf("--shell cannot be combined with --format or --export")
+			}
+			if len(keys) == 0 {
+				keys = make([]string, 0, len(loaded))
+				for k := range loaded {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+			}
+			var present []string
+			for _, k := range keys {
+				if _, ok := loaded[k]; ok {
+					present = append(present, k)
+				} else {
+					fmt.Fprintln(os.Stderr, color.YellowString("# %s not set", k))
+				}
+			}
+			script, err := env.ShellScript(shell, present, loaded.Values())
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(os.Stdout, script)
+			warnSecretsOnTerminal(present, settings.Secrets)
+			return nil
+		}
+
+		// display returns a value for human-readable output.
+		display := func(k, v string) string {
+			if !reveal {
+				v = env.Mask(k, v, settings.Secrets)
+			}
+			return displayValue(v)
+		}
 
 		if len(keys) > 0 {
 			// Print only requested keys.
+			var printed []string
 			for _, k := range keys {
 				v, ok := loaded[k]
 				if !ok {
-					color.Yellow("# %s not set", k)
+					fmt.Fprintln(os.Stderr, color.YellowString("# %s not set", k))
 					continue
 				}
-				if exportFormat {
-					fmt.Fprintf(os.Stdout, "export %s=%q\n", k, v)
+				if format != "" {
+					printed = append(printed, k)
 				} else {
-					fmt.Fprintf(os.Stdout, "%s=%s\n", k, v)
+					fmt.Fprintf(os.Stdout, "%s=%s\n", k, display(k, v.Value))
+				}
+			}
+			if format != "" {
+				if err := env.Export(os.Stdout, format, printed, loaded.Values()); err != nil {
+					return err
 				}
 			}
+			warnSecretsOnTerminal(printed, settings.Secrets)
 			return nil
 		}
 
@@ -114,17 +140,18 @@ Examples:
 		}
 		sort.Strings(sortedKeys)
 
-		if exportFormat {
-			for _, k := range sortedKeys {
-				fmt.Fprintf(os.Stdout, "export %s=%q\n", k, loaded[k])
+		if format != "" {
+			if err := env.Export(os.Stdout, format, sortedKeys, loaded.Values()); err != nil {
+				return err
 			}
+			warnSecretsOnTerminal(sortedKeys, settings.Secrets)
 		} else {
 			color.Cyan("» project: %s | env: %s", projectName, envName)
 			w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
 			bold := color.New(color.Bold)
 			bold.Fprintf(w, "KEY\tVALUE\tSOURCE\n")
 			for _, k := range sortedKeys {
-				fmt.Fprintf(w, "%s\t%s\t\n", k, loaded[k])
+				fmt.Fprintf(w, "%s\t%s\t%s\n", k, display(k, loaded[k].Value), loaded[k].Source)
 			}
 			w.Flush()
 		}
@@ -134,10 +161,18 @@ Examples:
 }
 
 func init() {
-	envGetCmd.Flags().BoolP("export", "x", false, "output in export format (for eval)")
+	envGetCmd.Flags().BoolP("export", "x", false, "output in export format (for eval); same as --format posix")
+	envGetCmd.Flags().String("format", "", "output format: "+strings.Join(env.ExportFormats, ", "))
+	envGetCmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(env.ExportFormats, cobra.ShellCompDirectiveNoFileComp))
+	envGetCmd.Flags().String("shell", "", "output statements for this shell: "+strings.Join(env.Shells, ", ")+", or auto to detect it from $SHELL")
+	envGetCmd.RegisterFlagCompletionFunc("shell", cobra.FixedCompletions(append([]string{"auto"}, env.Shells...), cobra.ShellCompDirectiveNoFileComp))
+	envGetCmd.Flags().Bool("unload", false, "output statements that restore the variables replaced by the last --shell load")
+	envGetCmd.Flags().Bool("reveal", false, "show secret values instead of masking them")
+	envListCmd.Flags().Bool("reveal", false, "show secret override values instead of masking them")
 
 	envAddCmd.Flags().StringSliceVarP(&envAddFiles, "files", "f", nil, "env files (comma-separated or repeated)")
 	envAddCmd.Flags().StringSliceVarP(&envAddOverrides, "override", "o", nil, "env overrides as KEY=VALUE (comma-separated or repeated)")
+	envAddCmd.Flags().StringSliceVarP(&envAddExtends, "extends", "e", nil, "envs to inherit files and overrides from (comma-separated or repeated)")
 
 	envCmd.AddCommand(envListCmd)
 	envCmd.AddCommand(envAddCmd)
@@ -147,6 +182,28 @@ func init() {
 	rootCmd.AddCommand(envCmd)
 }
 
+// displayValue escapes line breaks and tabs so values fit in a table row.
+func displayValue(v string) string {
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`, "\t", `\t`).Replace(v)
+}
+
+// warnSecretsOnTerminal prints a warning to stderr if secret values among
+// keys were just written to an interactive terminal.
+func warnSecretsOnTerminal(keys []string, patterns []string) {
+	if !isatty.IsTerminal(os.Stdout.Fd()) && !isatty.IsCygwinTerminal(os.Stdout.Fd()) {
+		return
+	}
+	var secret []string
+	for _, k := range keys {
+		if env.IsSecret(k, patterns) {
+			secret = append(secret, k)
+		}
+	}
+	if len(secret) > 0 {
+		fmt.Fprintln(os.Stderr, color.YellowString("warning: wrote %d secret value(s) to the terminal: %s", len(secret), strings.Join(secret, ", ")))
+	}
+}
+
 // getEnvNames returns all env names for a given project (for shell completion).
 func getEnvNames(projectName string) []string {
 	cfg, err := config.Load()
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/akpatel363/menv/internal/env"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var envLintCmd = &cobra.Command{
+	Use:   "lint [project] <env>",
+	Short: "Check an environment's env files for problems",
+	Long: `Parses every env file of the given project/env and reports malformed lines,
+duplicate keys, keys that are not valid shell identifiers, unterminated
+quotes and trailing whitespace, each with its file and line number.
+Exits with a non-zero status if any problem is found.
+
+Set 'strict: true' on an env to make 'menv run' fail on the same problems.
+
+Examples:
+  menv env lint my-app prod
+  menv env lint prod                   # auto-detect project from CWD`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+
+		_, project, envName, extra, err := resolveEnvArgs(cfg, args)
+		if err != nil {
+			return err
+		}
+		if len(extra) > 0 {
+			return fmt.Errorf("unexpected argument %q (expected [project] <env>)", extra[0])
+		}
+
+		issues, err := env.Lint(cfg, project, envName)
+		if err != nil {
+			return err
+		}
+
+		if len(issues) == 0 {
+			color.Green("✓ No problems found.")
+			return nil
+		}
+
+		for _, is := range issues {
+			fmt.Printf("%s %s\n", color.YellowString("%s:%d:", is.File, is.Line), is.Message)
+		}
+		cmd.SilenceUsage = true
+		return fmt.Errorf("found %d problem(s)", len(issues))
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envLintCmd)
+}
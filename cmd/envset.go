@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/env"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var envEditFile string
+
+// completeEnvArgs completes "[project] <env>" for commands that take
+// further arguments after the env.
+func completeEnvArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		suggestions := getProjectNames()
+		cfg, err := config.Load()
+		if err == nil {
+			if detected, _ := config.DetectProject(cfg); detected != "" {
+				suggestions = append(suggestions, getEnvNames(detected)...)
+			}
+		}
+		return suggestions, cobra.ShellCompDirectiveNoFileComp
+	case 1:
+		return getEnvNames(args[0]), cobra.ShellCompDirectiveNoFileComp
+	default:
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// --- env set ---
+
+var envSetCmd = &cobra.Command{
+	Use:   "set [project] <env> KEY=VALUE...",
+	Short: "Set variables in an env's overrides or in one of its files",
+	Long: `Sets one or more variables. By default they are written to the env's
+overrides in the config file. With --file they are written to a dotenv
+file instead (relative to the project path), keeping its comments,
+ordering and quoting; a backup is saved as <file>.bak first.
+If you are inside a project directory, the project name can be omitted.
+
+Examples:
+  menv env set my-app dev LOG_LEVEL=debug
+  menv env set dev PORT=8080 HOST=localhost     # auto-detect project from CWD
+  menv env set dev API_URL=http://localhost:3000 --file .env.dev`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		projectName, project, envName, assignments, err := resolveEnvArgs(cfg, args)
+		if err != nil {
+			return err
+		}
+		if len(assignments) == 0 {
+			return fmt.Errorf("nothing to set (expected KEY=VALUE)")
+		}
+
+		edits := make([]env.Edit, len(assignments))
+		keys := make([]string, len(assignments))
+		for i, a := range assignments {
+			key, value, ok := strings.Cut(a, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid assignment %q (expected KEY=VALUE)", a)
+			}
+			if !env.IsIdentifier(key) {
+				return fmt.Errorf("invalid key %q: not a valid shell identifier", key)
+			}
+			edits[i] = env.Edit{Key: key, Value: value}
+			keys[i] = key
+		}
+
+		if envEditFile != "" {
+			return editEnvFile(cfg, project, envName, edits, "Set %s in %s.")
+		}
+
+		e := project.Envs[envName]
+		if e.Overrides == nil {
+			e.Overrides = make(map[string]string)
+		}
+		for _, ed := range edits {
+			e.Overrides[ed.Key] = ed.Value
+		}
+		project.Envs[envName] = e
+		cfg.Projects[projectName] = project
+
+		if err := config.Save(cfg); err != nil {
+			return err
+		}
+		color.Green("✓ Set %s in the overrides of %q.", strings.Join(keys, ", "), envName)
+		return nil
+	},
+}
+
+// --- env unset ---
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset [project] <env> KEY...",
+	Short: "Remove variables from an env's overrides or from one of its files",
+	Long: `Removes one or more variables from the env's overrides in the config
+file, or with --file from a dotenv file (relative to the project path).
+Nothing is changed if any of the keys is not set there. When editing a
+file, a backup is saved as <file>.bak first.
+If you are inside a project directory, the project name can be omitted.
+
+Examples:
+  menv env unset my-app dev LOG_LEVEL
+  menv env unset dev OLD_FLAG --file .env.dev   # auto-detect project from CWD`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		projectName, project, envName, keys, err := resolveEnvArgs(cfg, args)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("nothing to unset (expected one or more keys)")
+		}
+
+		if envEditFile != "" {
+			edits := make([]env.Edit, len(keys))
+			for i, k := range keys {
+				edits[i] = env.Edit{Key: k, Unset: true}
+			}
+			return editEnvFile(cfg, project, envName, edits, "Removed %s from %s.")
+		}
+
+		e := project.Envs[envName]
+		var missing []string
+		for _, k := range keys {
+			if _, ok := e.Overrides[k]; !ok {
+				missing = append(missing, k)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return fmt.Errorf("%s not set in the overrides of %q", strings.Join(missing, ", "), envName)
+		}
+		for _, k := range keys {
+			delete(e.Overrides, k)
+		}
+		project.Envs[envName] = e
+		cfg.Projects[projectName] = project
+
+		if err := config.Save(cfg); err != nil {
+			return err
+		}
+		color.Green("✓ Removed %s from the overrides of %q.", strings.Join(keys, ", "), envName)
+		return nil
+	},
+}
+
+// editEnvFile applies edits to the dotenv file named by --file and reports
+// the result with msg, which takes the keys and the file. It warns when the
+// file is not one the env loads.
+func editEnvFile(cfg *config.Config, project config.Project, envName string, edits []env.Edit, msg string) error {
+	ref := config.FileRef{Path: envEditFile}
+	settings, err := envSettings(cfg, project, envName)
+	if err != nil {
+		return err
+	}
+	loaded := false
+	for _, f := range settings.Files {
+		if f.Path == envEditFile {
+			ref, loaded = f, true
+		}
+	}
+
+	if err := env.EditDotenv(project, ref, edits); err != nil {
+		return err
+	}
+
+	keys := make([]string, len(edits))
+	for i, e := range edits {
+		keys[i] = e.Key
+	}
+	color.Green("✓ "+msg, strings.Join(keys, ", "), envEditFile)
+	if !loaded {
+		color.Yellow("  Note: %s is not in the files of %q, so the change does not affect it.", envEditFile, envName)
+	}
+	return nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{envSetCmd, envUnsetCmd} {
+		c.Flags().StringVarP(&envEditFile, "file", "f", "", "edit this dotenv file (relative to the project path) instead of the overrides")
+		envCmd.AddCommand(c)
+	}
+}
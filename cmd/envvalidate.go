@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/akpatel363/menv/internal/env"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var envValidateCmd = &cobra.Command{
+	Use:   "validate [project] <env>",
+	Short: "Check an environment against its schema",
+	Long: `Resolves the given project/env and checks the result against the schema
+declared at project and env level. Prints every violation and exits with a
+non-zero status if there are any, which makes it suitable for CI.
+
+Examples:
+  menv env validate my-app prod
+  menv env validate prod               # auto-detect project from CWD`,
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeEnvArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+
+		projectName, project, envName, extra, err := resolveEnvArgs(cfg, args)
+		if err != nil {
+			return err
+		}
+		if len(extra) > 0 {
+			return fmt.Errorf("unexpected argument %q (expected [project] <env>)", extra[0])
+		}
+
+		settings, err := envSettings(cfg, project, envName)
+		if err != nil {
+			return err
+		}
+		loaded, err := env.LoadEnv(cfg, project, envName, buildOptions(settings, false))
+		if err != nil {
+			return err
+		}
+		schema, err := env.Schema(cfg, project, envName)
+		if err != nil {
+			return err
+		}
+
+		if len(schema) == 0 {
+			color.Yellow("No schema configured for %q.", envName)
+			return nil
+		}
+		violations := env.Validate(loaded, schema, settings.Secrets)
+		if len(violations) == 0 {
+			color.Green("✓ %s/%s satisfies its schema (%d key(s)).", projectName, envName, len(schema))
+			return nil
+		}
+
+		for _, v := range violations {
+			fmt.Printf("%s %s\n", color.RedString("%s:", v.Key), v.Message)
+		}
+		cmd.SilenceUsage = true
+		return fmt.Errorf("found %d violation(s)", len(violations))
+	},
+}
+
+func init() {
+	envCmd.AddCommand(envValidateCmd)
+}
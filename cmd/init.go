@@ -26,7 +26,7 @@ var initCmd = &cobra.Command{
 					Command: "echo hello",
 					Envs: map[string]config.Env{
 						"dev": {
-							Files:     []string{".env.dev"},
+							Files:     []config.FileRef{{Path: ".env.dev"}},
 							Overrides: map[string]string{"NODE_ENV": "development"},
 						},
 					},
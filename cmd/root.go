@@ -3,9 +3,15 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/env"
+	"github.com/akpatel363/menv/internal/runner"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
@@ -66,3 +72,147 @@ func resolveProject(cfg *config.Config, name string) (string, config.Project, er
 	}
 	return detected, *p, nil
 }
+
+// loadValidEnv loads an env and checks it against its schema.
+func loadValidEnv(cfg *config.Config, project config.Project, envName string, opts env.BuildOptions) (env.Vars, error) {
+	loaded, err := env.LoadEnv(cfg, project, envName, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchema(cfg, project, envName, loaded); err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// checkSchema checks loaded, the variables of an env, against its schema.
+func checkSchema(cfg *config.Config, project config.Project, envName string, loaded env.Vars) error {
+	schema, err := env.Schema(cfg, project, envName)
+	if err != nil {
+		return err
+	}
+	settings, err := envSettings(cfg, project, envName)
+	if err != nil {
+		return err
+	}
+	return env.CheckSchema(loaded, schema, settings.Secrets)
+}
+
+// envSettings returns the merged settings of every layer of an env.
+func envSettings(cfg *config.Config, project config.Project, envName string) (config.Env, error) {
+	chain, err := cfg.EnvChain(project, envName)
+	if err != nil {
+		return config.Env{}, err
+	}
+	return config.MergeLayers(chain), nil
+}
+
+// buildOptions returns the options to build the environment of an env with
+// the given settings; pure forces pure mode, as --pure does.
+func buildOptions(settings config.Env, pure bool) env.BuildOptions {
+	return env.BuildOptions{
+		Pure:    settings.Pure || pure,
+		Inherit: settings.Inherit,
+		Unset:   settings.Unset,
+	}
+}
+
+// envHooks returns the hooks of an env, ready to run in the project
+// directory, announcing each one as it starts.
+func envHooks(cfg *config.Config, project config.Project, envName string) (runner.Hooks, error) {
+	chain, err := cfg.EnvChain(project, envName)
+	if err != nil {
+		return runner.Hooks{}, err
+	}
+	hooks := project.EnvHooks(chain)
+	return runner.Hooks{
+		PreRun:    hooks.PreRun,
+		PostRun:   hooks.PostRun,
+		OnFailure: hooks.OnFailure,
+		Dir:       project.Path,
+		Notify: func(event, command string) {
+			color.Cyan("» %s: %s", event, command)
+		},
+	}, nil
+}
+
+// restartSettings converts restart settings from the config. A policy given
+// on the command line replaces the configured one.
+func restartSettings(r *config.Restart, policy string) (runner.Restart, error) {
+	var cfg config.Restart
+	if r != nil {
+		cfg = *r
+	}
+	if policy != "" {
+		cfg.Policy = policy
+	}
+	if cfg.Policy != "" && !slices.Contains(runner.RestartPolicies, cfg.Policy) {
+		return runner.Restart{}, fmt.Errorf("unknown restart policy %q (expected one of: %s)", cfg.Policy, strings.Join(runner.RestartPolicies, ", "))
+	}
+
+	restart := runner.Restart{Policy: cfg.Policy, MaxRetries: cfg.MaxRetries, FlapLimit: cfg.FlapLimit}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"backoff", cfg.Backoff, &restart.Backoff},
+		{"max_backoff", cfg.MaxBackoff, &restart.MaxBackoff},
+		{"min_uptime", cfg.MinUptime, &restart.MinUptime},
+	} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v <= 0 {
+			return runner.Restart{}, fmt.Errorf("invalid restart %s %q (expected a duration such as 5s)", d.name, d.value)
+		}
+		*d.dst = v
+	}
+	return restart, nil
+}
+
+// gracePeriod returns the project's shutdown grace period, or zero for the
+// default.
+func gracePeriod(project config.Project) (time.Duration, error) {
+	if project.Shutdown.GracePeriod == "" {
+		return 0, nil
+	}
+	grace, err := time.ParseDuration(project.Shutdown.GracePeriod)
+	if err != nil || grace <= 0 {
+		return 0, fmt.Errorf("invalid shutdown grace_period %q (expected a duration such as 10s)", project.Shutdown.GracePeriod)
+	}
+	return grace, nil
+}
+
+// resolveEnvArgs splits arguments of the form "[project] <env> <item>...".
+// The first two arguments name the project and env when they match a
+// project and one of its envs; otherwise the project is detected from the
+// CWD and the first argument is the env. The remaining arguments are returned
+// as items. The env must exist.
+func resolveEnvArgs(cfg *config.Config, args []string) (string, config.Project, string, []string, error) {
+	var projectName, envName string
+	var project config.Project
+	var err error
+
+	if p, ok := cfg.Projects[args[0]]; ok && len(args) > 1 {
+		if _, ok := p.Envs[args[1]]; ok {
+			projectName, project, envName, args = args[0], p, args[1], args[2:]
+		}
+	}
+	if projectName == "" {
+		if projectName, project, err = resolveProject(cfg, ""); err != nil {
+			// "<project> <typo>" outside a project directory.
+			if _, ok := cfg.Projects[args[0]]; ok && len(args) > 1 {
+				return "", config.Project{}, "", nil, fmt.Errorf("environment %q not found in project %q", args[1], args[0])
+			}
+			return "", config.Project{}, "", nil, err
+		}
+		envName, args = args[0], args[1:]
+	}
+
+	if _, exists := project.Envs[envName]; !exists {
+		return "", config.Project{}, "", nil, fmt.Errorf("environment %q not found in project %q", envName, projectName)
+	}
+	return projectName, project, envName, args, nil
+}
@@ -2,119 +2,362 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/akpatel363/menv/internal/config"
 	"github.com/akpatel363/menv/internal/env"
+	"github.com/akpatel363/menv/internal/provider"
 	"github.com/akpatel363/menv/internal/runner"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+var (
+	runPure    bool
+	runNoHooks bool
+	runRestart string
+	runWatch   bool
+
+	runWatchGlobs []string
+)
+
 var runCmd = &cobra.Command{
-	Use:   "run [project] <env> [-- command ...]",
+	Use:   "run [project] <env> [task] [-- command ...]",
 	Short: "Run a command with environment variables loaded",
 	Long: `Loads environment variables from the configured files and overrides
 for the given project/env, then executes the command.
 
-If no command is provided after --, the project's default command is used.
+If a task is named, its command is run instead, in its directory and with
+its overrides applied; arguments after -- are appended to it. A task with
+a default env can be run without naming the env. If no task or command
+is provided, the project's default command is used.
+
+Hooks configured for the project and env run before the command
+(pre_run), after it (post_run) and when it fails (on_failure), unless
+--no-hooks is given. Post-run hooks get its exit code in MENV_EXIT_CODE.
+
+With a restart policy (--restart, or restart in the config), the command
+is started again when it exits: always, or on-failure only. Restarts are
+delayed with exponential backoff and stop after max_retries, or when the
+command keeps crashing right after starting.
+
+With --watch, the command is restarted whenever one of the env's files,
+the config file or a file matching the project's watch patterns or
+--watch-glob changes: the env is reloaded, the command gets SIGTERM and,
+after the grace period (shutdown.grace_period, 10s by default), SIGKILL,
+and it is started again with the new variables and the current task or
+default command. Changes to hooks need a restart of menv. In watch mode
+the command does not read from the terminal.
 If you are inside a project directory, the project name can be omitted.
 
 Examples:
   menv run my-app dev
   menv run dev                         # auto-detect project from CWD
   menv run my-app dev -- npm run build
-  menv run dev -- npm run build        # auto-detect + custom command`,
+  menv run dev -- npm run build        # auto-detect + custom command
+  menv run dev test -- -run TestLogin  # run the test task with extra args
+  menv run migrate                     # task with a default env
+  menv run --pure my-app dev           # start from an empty environment
+  menv run --no-hooks dev              # skip the project's and env's hooks
+  menv run dev --restart on-failure    # start it again when it crashes
+  menv run dev --watch                 # restart when .env files change
+  menv run dev -w --watch-glob 'config/*.yaml'`,
 	Args:                  cobra.MinimumNArgs(1),
 	DisableFlagParsing:    false,
 	DisableFlagsInUseLine: true,
 	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		detected, _ := config.DetectProject(cfg)
+		isProject := false
+		if len(args) > 0 {
+			_, isProject = cfg.Projects[args[0]]
+		}
+
 		switch len(args) {
 		case 0:
-			// Could be a project name or env name (if CWD-detected).
+			// Could be a project name, or an env or task (if CWD-detected).
 			suggestions := getProjectNames()
-			cfg, err := config.Load()
-			if err == nil {
-				if detected, _ := config.DetectProject(cfg); detected != "" {
-					suggestions = append(suggestions, getEnvNames(detected)...)
-				}
+			if detected != "" {
+				suggestions = append(suggestions, getEnvNames(detected)...)
+				suggestions = append(suggestions, getTaskNames(detected, true)...)
 			}
 			return suggestions, cobra.ShellCompDirectiveNoFileComp
 		case 1:
-			return getEnvNames(args[0]), cobra.ShellCompDirectiveNoFileComp
-		default:
-			return nil, cobra.ShellCompDirectiveNoFileComp
+			if isProject {
+				return append(getEnvNames(args[0]), getTaskNames(args[0], true)...), cobra.ShellCompDirectiveNoFileComp
+			}
+			return getTaskNames(detected, false), cobra.ShellCompDirectiveNoFileComp
+		case 2:
+			if isProject {
+				return getTaskNames(args[0], false), cobra.ShellCompDirectiveNoFileComp
+			}
 		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := loadConfig()
 
-		var projectName, envName string
-		var project config.Project
-
 		argsBeforeDash := args
 		dashIdx := cmd.ArgsLenAtDash()
 		if dashIdx >= 0 {
 			argsBeforeDash = args[:dashIdx]
 		}
 
-		switch len(argsBeforeDash) {
-		case 1:
-			// Only env name provided — detect project from CWD.
-			envName = argsBeforeDash[0]
-			var err error
-			projectName, project, err = resolveProject(cfg, "")
-			if err != nil {
-				return err
-			}
-		case 2:
-			// Both project and env provided.
-			projectName = argsBeforeDash[0]
-			envName = argsBeforeDash[1]
-			var err error
-			_, project, err = resolveProject(cfg, projectName)
-			if err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("expected 1 or 2 positional arguments (env or project env), got %d", len(argsBeforeDash))
-		}
-
-		envCfg, exists := project.Envs[envName]
-		if !exists {
-			return fmt.Errorf("environment %q not found in project %q", envName, projectName)
+		projectName, project, envName, taskName, err := resolveRunArgs(cfg, argsBeforeDash)
+		if err != nil {
+			return err
 		}
 
-		// Determine the command to run.
-		var cmdToRun []string
+		var extra []string
 		if dashIdx >= 0 && dashIdx < len(args) {
-			cmdToRun = args[dashIdx:]
-		} else if project.Command != "" {
-			cmdToRun = []string{project.Command}
-		} else {
-			return fmt.Errorf("no command provided and no default command configured for project %q", projectName)
+			extra = args[dashIdx:]
 		}
+		cmdToRun, workDir, err := runCommand(projectName, project, taskName, extra)
+		if err != nil {
+			return err
+		}
+		task, isTask := project.Tasks[taskName]
 
-		// Load env variables.
-		loaded, err := env.LoadEnv(project, envCfg)
+		restartCfg := project.Restart
+		if isTask && task.Restart != nil {
+			restartCfg = task.Restart
+		}
+		restart, err := restartSettings(restartCfg, runRestart)
 		if err != nil {
 			return err
 		}
 
-		envVars := env.BuildEnv(loaded)
+		loaded, settings, opts, err := loadRunEnv(cfg, project, envName, taskName)
+		if err != nil {
+			return err
+		}
+		envVars := env.BuildEnv(loaded.Values(), opts)
 
-		color.Cyan("» project: %s | env: %s", projectName, envName)
-		color.Cyan("» directory: %s", project.Path)
+		if isTask {
+			color.Cyan("» project: %s | env: %s | task: %s", projectName, envName, taskName)
+		} else {
+			color.Cyan("» project: %s | env: %s", projectName, envName)
+		}
+		color.Cyan("» directory: %s", workDir)
 		if len(loaded) > 0 {
 			color.HiBlack("  loaded %d env variable(s)", len(loaded))
 		}
+		if opts.Pure {
+			color.HiBlack("  pure environment: %d OS variable(s) inherited", len(env.BaseEnv(opts)))
+		}
 		color.Cyan("» running: %v", cmdToRun)
 		fmt.Println()
 
-		return runner.Run(cmdToRun, envVars, project.Path)
+		notify := func(msg string) {
+			color.Yellow("» %s", msg)
+		}
+		run := func() error {
+			return runner.RunWithRestart(cmdToRun, envVars, workDir, restart, notify)
+		}
+		var hooks runner.Hooks
+		if !runNoHooks {
+			if hooks, err = envHooks(cfg, project, envName); err != nil {
+				return err
+			}
+		}
+		if runWatch {
+			grace, err := gracePeriod(project)
+			if err != nil {
+				return err
+			}
+			paths, globs := watchTargets(project, settings)
+			run = func() error {
+				return runner.RunWatch(cmdToRun, envVars, workDir, runner.Watch{
+					Paths:       paths,
+					Globs:       globs,
+					GracePeriod: grace,
+					Restart:     restart,
+					Notify:      notify,
+					Reload: func() (runner.Reloaded, error) {
+						return reloadRunEnv(projectName, envName, taskName, extra, hooks)
+					},
+				})
+			}
+		}
+		if runNoHooks {
+			return run()
+		}
+		return hooks.Around(envVars, run)
 	},
 }
 
+// runCommand returns the command to run and its working directory: the
+// task's command with extra appended, extra on its own, or the project's
+// default command.
+func runCommand(projectName string, project config.Project, taskName string, extra []string) ([]string, string, error) {
+	workDir := project.Path
+	if task, isTask := project.Tasks[taskName]; isTask {
+		if task.Dir != "" {
+			workDir = task.Dir
+			if !filepath.IsAbs(workDir) {
+				workDir = filepath.Join(project.Path, workDir)
+			}
+		}
+		return append([]string{task.Command}, extra...), workDir, nil
+	}
+	if len(extra) > 0 {
+		return extra, workDir, nil
+	}
+	if project.Command != "" {
+		return []string{project.Command}, workDir, nil
+	}
+	return nil, "", fmt.Errorf("no command provided and no default command configured for project %q", projectName)
+}
+
+// loadRunEnv loads the variables for running in an env, with the overrides
+// of the task, if any, on top, and checks them against the schema. It also
+// returns the env's settings and the options to build the environment with.
+func loadRunEnv(cfg *config.Config, project config.Project, envName, taskName string) (env.Vars, config.Env, env.BuildOptions, error) {
+	settings, err := envSettings(cfg, project, envName)
+	if err != nil {
+		return nil, config.Env{}, env.BuildOptions{}, err
+	}
+	opts := buildOptions(settings, runPure)
+	loaded, err := env.LoadEnv(cfg, project, envName, opts)
+	if err != nil {
+		return nil, config.Env{}, env.BuildOptions{}, err
+	}
+	if task, isTask := project.Tasks[taskName]; isTask {
+		if err := env.ApplyOverrides(loaded, project, task.Overrides, "task "+taskName, opts); err != nil {
+			return nil, config.Env{}, env.BuildOptions{}, fmt.Errorf("task %s: %w", taskName, err)
+		}
+	}
+	if err := checkSchema(cfg, project, envName, loaded); err != nil {
+		return nil, config.Env{}, env.BuildOptions{}, err
+	}
+	return loaded, settings, opts, nil
+}
+
+// reloadRunEnv reads the config file again and reloads an env for --watch,
+// fetching references and secrets anew. It returns the command to restart,
+// rebuilt from the task or project, and what to watch from then on. Hooks
+// only run around the whole watch, so changes to them are reported rather
+// than applied.
+func reloadRunEnv(projectName, envName, taskName string, extra []string, hooks runner.Hooks) (runner.Reloaded, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return runner.Reloaded{}, err
+	}
+	project, ok := cfg.Projects[projectName]
+	if !ok {
+		return runner.Reloaded{}, fmt.Errorf("project %q not found", projectName)
+	}
+	if _, ok := project.Tasks[taskName]; taskName != "" && !ok {
+		return runner.Reloaded{}, fmt.Errorf("task %q not found in project %q", taskName, projectName)
+	}
+	cmdToRun, workDir, err := runCommand(projectName, project, taskName, extra)
+	if err != nil {
+		return runner.Reloaded{}, err
+	}
+
+	provider.Default.Reset()
+	loaded, settings, opts, err := loadRunEnv(cfg, project, envName, taskName)
+	if err != nil {
+		return runner.Reloaded{}, err
+	}
+	if !runNoHooks {
+		if h, err := envHooks(cfg, project, envName); err == nil && !sameHooks(h, hooks) {
+			color.Yellow("» hooks changed; restart menv to apply them")
+		}
+	}
+
+	color.HiBlack("  loaded %d env variable(s)", len(loaded))
+	color.HiBlack("  running: %v in %s", cmdToRun, workDir)
+	paths, globs := watchTargets(project, settings)
+	return runner.Reloaded{
+		Command: cmdToRun,
+		Env:     env.BuildEnv(loaded.Values(), opts),
+		Dir:     workDir,
+		Paths:   paths,
+		Globs:   globs,
+	}, nil
+}
+
+// sameHooks reports whether a and b run the same hook commands in the same
+// directory.
+func sameHooks(a, b runner.Hooks) bool {
+	return slices.Equal(a.PreRun, b.PreRun) && slices.Equal(a.PostRun, b.PostRun) &&
+		slices.Equal(a.OnFailure, b.OnFailure) && a.Dir == b.Dir
+}
+
+// watchTargets returns the files --watch watches: the env's files and the
+// config file, and the glob patterns from the project's watch list and
+// --watch-glob, all as absolute paths.
+func watchTargets(project config.Project, settings config.Env) (paths, globs []string) {
+	abs := func(p string) string {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(project.Path, p)
+		}
+		return filepath.Clean(p)
+	}
+	for _, f := range settings.Files {
+		paths = append(paths, abs(f.Path))
+	}
+	if p, err := filepath.Abs(config.GetConfigPath()); err == nil {
+		paths = append(paths, p)
+	}
+	for _, g := range append(append([]string{}, project.Watch...), runWatchGlobs...) {
+		globs = append(globs, abs(g))
+	}
+	return paths, globs
+}
+
+// resolveRunArgs splits the positional arguments of run: "[project] <env>
+// [task]", or "[project] <task>" for a task with a default env. The first
+// argument is the project when it names one and more arguments follow;
+// otherwise the project is detected from the CWD.
+func resolveRunArgs(cfg *config.Config, args []string) (projectName string, project config.Project, envName, taskName string, err error) {
+	if len(args) >= 2 {
+		if _, ok := cfg.Projects[args[0]]; ok {
+			projectName, args = args[0], args[1:]
+		}
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return "", config.Project{}, "", "", fmt.Errorf("expected [project] <env> [task], got %d positional argument(s)", len(args))
+	}
+	if projectName, project, err = resolveProject(cfg, projectName); err != nil {
+		return "", config.Project{}, "", "", err
+	}
+
+	envName = args[0]
+	if len(args) == 2 {
+		taskName = args[1]
+	} else if _, isEnv := project.Envs[envName]; !isEnv {
+		if task, isTask := project.Tasks[envName]; isTask {
+			taskName, envName = envName, task.Env
+			if envName == "" {
+				return "", config.Project{}, "", "", fmt.Errorf("task %q has no default env; use 'menv run <env> %s'", taskName, taskName)
+			}
+		}
+	}
+
+	if _, exists := project.Envs[envName]; !exists {
+		return "", config.Project{}, "", "", fmt.Errorf("environment %q not found in project %q", envName, projectName)
+	}
+	if _, exists := project.Tasks[taskName]; taskName != "" && !exists {
+		return "", config.Project{}, "", "", fmt.Errorf("task %q not found in project %q", taskName, projectName)
+	}
+	return projectName, project, envName, taskName, nil
+}
+
 func init() {
+	runCmd.Flags().BoolVar(&runPure, "pure", false, "start from an empty environment, passing through only inherited OS variables")
+	runCmd.Flags().BoolVar(&runNoHooks, "no-hooks", false, "skip the pre_run, post_run and on_failure hooks")
+	runCmd.Flags().StringVar(&runRestart, "restart", "", "restart the command when it exits: "+strings.Join(runner.RestartPolicies, ", ")+" (default from the config, or never)")
+	runCmd.RegisterFlagCompletionFunc("restart", cobra.FixedCompletions(runner.RestartPolicies, cobra.ShellCompDirectiveNoFileComp))
+	runCmd.Flags().BoolVarP(&runWatch, "watch", "w", false, "restart the command when the env files or the config change")
+	runCmd.Flags().StringSliceVar(&runWatchGlobs, "watch-glob", nil, "also watch files matching these patterns, relative to the project path (comma-separated or repeated)")
+
 	rootCmd.AddCommand(runCmd)
 }
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/akpatel363/menv/internal/crypt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "Encrypt and decrypt env files",
+	Long: `Encrypts env files so they can be committed next to the code.
+
+Files are sealed with AES-256-GCM, using either a local key file
+(default: $MENV_KEY_FILE or ~/.config/menv/key) or a passphrase
+($MENV_PASSPHRASE, or prompted). Files ending in .enc, or marked
+'encrypted: true' in an env's files list, are decrypted automatically
+when the env is loaded.
+
+  menv secret keygen
+  menv secret encrypt .secrets          # writes .secrets.enc
+  menv secret decrypt .secrets.enc      # writes .secrets`,
+}
+
+var (
+	secretKeyFile    string
+	secretPassphrase bool
+	secretOutput     string
+	secretForce      bool
+)
+
+// --- secret keygen ---
+
+var secretKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Create a new key file",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := secretKeyFile
+		if path == "" {
+			path = crypt.DefaultKeyFile()
+		}
+		if err := crypt.GenerateKey(path); err != nil {
+			return err
+		}
+		color.Green("✓ Key written to %s", path)
+		color.Cyan("  Share it only with people who should be able to decrypt your env files.")
+		return nil
+	},
+}
+
+// --- secret encrypt ---
+
+var secretEncryptCmd = &cobra.Command{
+	Use:   "encrypt <file>",
+	Short: "Encrypt a file (writes <file>.enc)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in := args[0]
+		out := secretOutput
+		if out == "" {
+			out = in + ".enc"
+		}
+
+		plaintext, err := os.ReadFile(in)
+		if err != nil {
+			return err
+		}
+		if crypt.IsEncrypted(plaintext) {
+			return fmt.Errorf("%s is already encrypted", in)
+		}
+
+		sealed, err := crypt.Encrypt(plaintext, &crypt.Keys{KeyFile: secretKeyFile}, secretPassphrase)
+		if err != nil {
+			return err
+		}
+		if err := writeSecretOutput(out, sealed); err != nil {
+			return err
+		}
+
+		if out != "-" {
+			color.Green("✓ Encrypted %s → %s", in, out)
+			color.Cyan("  Remember to delete or git-ignore the plaintext file.")
+		}
+		return nil
+	},
+}
+
+// --- secret decrypt ---
+
+var secretDecryptCmd = &cobra.Command{
+	Use:   "decrypt <file>",
+	Short: "Decrypt a file (writes <file> without .enc, or use -o -)",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in := args[0]
+		out := secretOutput
+		if out == "" {
+			out = strings.TrimSuffix(in, ".enc")
+			if out == in {
+				return fmt.Errorf("%s does not end in .enc; use --output to choose where to write", in)
+			}
+		}
+
+		sealed, err := os.ReadFile(in)
+		if err != nil {
+			return err
+		}
+		plaintext, err := crypt.Decrypt(sealed, &crypt.Keys{KeyFile: secretKeyFile})
+		if err != nil {
+			return err
+		}
+		if err := writeSecretOutput(out, plaintext); err != nil {
+			return err
+		}
+
+		if out != "-" {
+			color.Green("✓ Decrypted %s → %s", in, out)
+		}
+		return nil
+	},
+}
+
+// writeSecretOutput writes data to path ("-" for stdout) with owner-only
+// permissions, refusing to overwrite an existing file without --force.
+func writeSecretOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !secretForce {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func init() {
+	secretCmd.PersistentFlags().StringVar(&secretKeyFile, "key-file", "", "key file (default: $MENV_KEY_FILE or ~/.config/menv/key)")
+
+	secretEncryptCmd.Flags().BoolVarP(&secretPassphrase, "passphrase", "p", false, "derive the key from a passphrase instead of the key file")
+	for _, c := range []*cobra.Command{secretEncryptCmd, secretDecryptCmd} {
+		c.Flags().StringVarP(&secretOutput, "output", "o", "", "output file, or - for stdout")
+		c.Flags().BoolVar(&secretForce, "force", false, "overwrite the output file if it exists")
+	}
+
+	secretCmd.AddCommand(secretKeygenCmd)
+	secretCmd.AddCommand(secretEncryptCmd)
+	secretCmd.AddCommand(secretDecryptCmd)
+
+	rootCmd.AddCommand(secretCmd)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/akpatel363/menv/internal/config"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var tasksCmd = &cobra.Command{
+	Use:   "tasks [project]",
+	Short: "List the tasks of a project",
+	Long: `Lists the tasks defined under a project's tasks, with their default
+env, working directory and description. Run one with
+'menv run [project] <env> <task>'.
+If you are inside a project directory, the project name can be omitted.
+
+Examples:
+  menv tasks my-app
+  menv tasks                           # auto-detect project from CWD`,
+	Args: cobra.MaximumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return getProjectNames(), cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		projectName, project, err := resolveProject(cfg, name)
+		if err != nil {
+			return err
+		}
+
+		if len(project.Tasks) == 0 {
+			color.Yellow("No tasks configured for %q. Add them under 'tasks' in the config file.", projectName)
+			return nil
+		}
+
+		names := make([]string, 0, len(project.Tasks))
+		for n := range project.Tasks {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		bold := color.New(color.Bold)
+		bold.Fprintf(w, "TASK\tENV\tDIR\tCOMMAND\tDESCRIPTION\n")
+		for _, n := range names {
+			t := project.Tasks[n]
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", n, t.Env, t.Dir, t.Command, t.Description)
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+// getTaskNames returns the task names of a project (for shell completion).
+// With withEnv, only tasks that have a default env are returned.
+func getTaskNames(projectName string, withEnv bool) []string {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil
+	}
+	project, exists := cfg.Projects[projectName]
+	if !exists {
+		return nil
+	}
+	names := make([]string, 0, len(project.Tasks))
+	for n, t := range project.Tasks {
+		if !withEnv || t.Env != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(tasksCmd)
+}
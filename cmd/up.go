@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/env"
+	"github.com/akpatel363/menv/internal/runner"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	upPure     bool
+	upNoHooks  bool
+	upShutdown string
+	upRestart  string
+)
+
+var upCmd = &cobra.Command{
+	Use:   "up [project] <env> [process...]",
+	Short: "Start all of a project's processes with an env loaded",
+	Long: `Starts the processes listed under the project's processes, all at once
+and each with the env's variables, and shows their output line by line,
+prefixed with a timestamp and the process name. Name processes to start
+only those.
+
+When a process exits, the others are stopped according to the shutdown
+policy: "exit" (default) stops them when any process exits, "failure"
+only when one fails, and "never" leaves them running. Stopped processes
+get SIGTERM, then SIGKILL after the grace period. Ctrl-C is passed on to
+every process; press it again to kill them at once.
+
+Processes with a restart policy (--restart, or restart in the config) are
+started again when they exit, with exponential backoff; the shutdown
+policy only applies once a process is no longer restarted.
+
+The project's and env's hooks run before the processes start and after
+they have all exited, unless --no-hooks is given.
+If you are inside a project directory, the project name can be omitted.
+
+Examples:
+  menv up my-app dev
+  menv up dev                          # auto-detect project from CWD
+  menv up dev api worker               # start only some processes
+  menv up dev --shutdown failure       # keep going when a process exits cleanly`,
+	Args: cobra.MinimumNArgs(1),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		// Once the env is known, complete process names.
+		if cfg, err := config.Load(); err == nil && len(args) > 0 {
+			if _, project, _, _, err := resolveEnvArgs(cfg, args); err == nil {
+				return slices.Sorted(maps.Keys(project.Processes)), cobra.ShellCompDirectiveNoFileComp
+			}
+		}
+		return completeEnvArgs(cmd, args, toComplete)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := loadConfig()
+		projectName, project, envName, names, err := resolveEnvArgs(cfg, args)
+		if err != nil {
+			return err
+		}
+
+		if len(project.Processes) == 0 {
+			return fmt.Errorf("no processes configured for project %q; add them under 'processes' in the config file", projectName)
+		}
+		if len(names) == 0 {
+			names = make([]string, 0, len(project.Processes))
+			for n := range project.Processes {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+		}
+
+		policy := project.Shutdown.Policy
+		if upShutdown != "" {
+			policy = upShutdown
+		}
+		if policy != "" && !slices.Contains(runner.ShutdownPolicies, policy) {
+			return fmt.Errorf("unknown shutdown policy %q (expected one of: %s)", policy, strings.Join(runner.ShutdownPolicies, ", "))
+		}
+		grace, err := gracePeriod(project)
+		if err != nil {
+			return err
+		}
+
+		settings, err := envSettings(cfg, project, envName)
+		if err != nil {
+			return err
+		}
+		opts := buildOptions(settings, upPure)
+		loaded, err := loadValidEnv(cfg, project, envName, opts)
+		if err != nil {
+			return err
+		}
+
+		group := runner.Group{Shutdown: policy, GracePeriod: grace}
+		for _, name := range names {
+			p, ok := project.Processes[name]
+			if !ok {
+				return fmt.Errorf("process %q not found in project %q", name, projectName)
+			}
+			vars := loaded
+			if len(p.Overrides) > 0 {
+				vars = maps.Clone(loaded)
+				if err := env.ApplyOverrides(vars, project, p.Overrides, "process "+name, opts); err != nil {
+					return fmt.Errorf("process %s: %w", name, err)
+				}
+				if err := checkSchema(cfg, project, envName, vars); err != nil {
+					return fmt.Errorf("process %s: %w", name, err)
+				}
+			}
+			restartCfg := project.Restart
+			if p.Restart != nil {
+				restartCfg = p.Restart
+			}
+			restart, err := restartSettings(restartCfg, upRestart)
+			if err != nil {
+				return fmt.Errorf("process %s: %w", name, err)
+			}
+			dir := project.Path
+			if p.Dir != "" {
+				dir = p.Dir
+				if !filepath.IsAbs(dir) {
+					dir = filepath.Join(project.Path, dir)
+				}
+			}
+			group.Processes = append(group.Processes, runner.Process{
+				Name:    name,
+				Command: p.Command,
+				Dir:     dir,
+				Env:     env.BuildEnv(vars.Values(), opts),
+				Restart: restart,
+			})
+		}
+
+		color.Cyan("» project: %s | env: %s", projectName, envName)
+		color.Cyan("» directory: %s", project.Path)
+		if len(loaded) > 0 {
+			color.HiBlack("  loaded %d env variable(s)", len(loaded))
+		}
+		color.Cyan("» starting: %s", strings.Join(names, ", "))
+		fmt.Println()
+
+		if upNoHooks {
+			return group.Run()
+		}
+		hooks, err := envHooks(cfg, project, envName)
+		if err != nil {
+			return err
+		}
+		return hooks.Around(env.BuildEnv(loaded.Values(), opts), group.Run)
+	},
+}
+
+func init() {
+	upCmd.Flags().BoolVar(&upPure, "pure", false, "start from an empty environment, passing through only inherited OS variables")
+	upCmd.Flags().BoolVar(&upNoHooks, "no-hooks", false, "skip the pre_run, post_run and on_failure hooks")
+	upCmd.Flags().StringVar(&upShutdown, "shutdown", "", "when to stop the other processes: "+strings.Join(runner.ShutdownPolicies, ", ")+" (default from the config, or exit)")
+	upCmd.RegisterFlagCompletionFunc("shutdown", cobra.FixedCompletions(runner.ShutdownPolicies, cobra.ShellCompDirectiveNoFileComp))
+	upCmd.Flags().StringVar(&upRestart, "restart", "", "restart processes when they exit: "+strings.Join(runner.RestartPolicies, ", ")+" (default from the config, or never)")
+	upCmd.RegisterFlagCompletionFunc("restart", cobra.FixedCompletions(runner.RestartPolicies, cobra.ShellCompDirectiveNoFileComp))
+
+	rootCmd.AddCommand(upCmd)
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Names of the layers that come from Config.Defaults and Project.Base.
+const (
+	DefaultsLayer = "defaults"
+	BaseLayer     = "base"
+)
+
+// Layer is one level of configuration contributing to a resolved env.
+type Layer struct {
+	// Name is the env the layer comes from, or DefaultsLayer / BaseLayer.
+	Name string
+	Env  Env
+}
+
+// EnvChain returns every layer of the env called name in project, lowest
+// precedence first:
+//
+//  1. the global defaults block (Config.Defaults)
+//  2. the project's base block (Project.Base)
+//  3. the envs it extends, see Project.EnvChain
+//  4. the env itself
+//
+// `extends` is ignored inside defaults and base blocks.
+func (c *Config) EnvChain(project Project, name string) ([]Layer, error) {
+	chain, err := project.EnvChain(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var layers []Layer
+	if c.Defaults != nil {
+		layers = append(layers, Layer{Name: DefaultsLayer, Env: *c.Defaults})
+	}
+	if project.Base != nil {
+		layers = append(layers, Layer{Name: BaseLayer, Env: *project.Base})
+	}
+	return append(layers, chain...), nil
+}
+
+// EnvChain returns the layers that make up the env called name, lowest
+// precedence first: every env it (transitively) extends, then the env itself.
+// Parents listed in `extends` are applied in order, so later parents win over
+// earlier ones. An env reachable through several paths appears only once.
+func (p Project) EnvChain(name string) ([]Layer, error) {
+	if _, ok := p.Envs[name]; !ok {
+		return nil, fmt.Errorf("environment %q not found", name)
+	}
+
+	var chain []Layer
+	done := make(map[string]bool)
+	var stack []string
+
+	var visit func(n string) error
+	visit = func(n string) error {
+		if done[n] {
+			return nil
+		}
+		for i, s := range stack {
+			if s == n {
+				cycle := append(append([]string{}, stack[i:]...), n)
+				return fmt.Errorf("extends cycle: %s", strings.Join(cycle, " -> "))
+			}
+		}
+
+		e, ok := p.Envs[n]
+		if !ok {
+			return fmt.Errorf("environment %q extends unknown environment %q", stack[len(stack)-1], n)
+		}
+
+		stack = append(stack, n)
+		for _, parent := range e.Extends {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+
+		done[n] = true
+		chain = append(chain, Layer{Name: n, Env: e})
+		return nil
+	}
+
+	if err := visit(name); err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// MergeLayers combines the settings of layers into a single Env, in order.
+// Files are concatenated and overrides merged, later layers winning; boolean
+// options are enabled if any layer enables them and lists are concatenated.
+// Extends is not carried over.
+func MergeLayers(layers []Layer) Env {
+	var merged Env
+	for _, l := range layers {
+		e := l.Env
+		merged.Files = append(merged.Files, e.Files...)
+		for k, v := range e.Overrides {
+			if merged.Overrides == nil {
+				merged.Overrides = make(map[string]string)
+			}
+			merged.Overrides[k] = v
+		}
+		merged.Strict = merged.Strict || e.Strict
+		merged.Pure = merged.Pure || e.Pure
+		merged.Inherit = append(merged.Inherit, e.Inherit...)
+		merged.Unset = append(merged.Unset, e.Unset...)
+		merged.Secrets = append(merged.Secrets, e.Secrets...)
+		merged.Hooks.PreRun = append(merged.Hooks.PreRun, e.Hooks.PreRun...)
+		merged.Hooks.PostRun = append(merged.Hooks.PostRun, e.Hooks.PostRun...)
+		merged.Hooks.OnFailure = append(merged.Hooks.OnFailure, e.Hooks.OnFailure...)
+		for k, v := range e.Schema {
+			if merged.Schema == nil {
+				merged.Schema = make(map[string]SchemaField)
+			}
+			merged.Schema[k] = v
+		}
+	}
+	return merged
+}
+
+// EnvSchema returns the schema that applies to an env made of layers: the
+// project's schema, overlaid with the schema of each layer in order.
+func (p Project) EnvSchema(layers []Layer) map[string]SchemaField {
+	schema := make(map[string]SchemaField, len(p.Schema))
+	for k, v := range p.Schema {
+		schema[k] = v
+	}
+	for k, v := range MergeLayers(layers).Schema {
+		schema[k] = v
+	}
+	return schema
+}
+
+// EnvHooks returns the hooks that apply to an env made of layers: the
+// project's hooks, followed by those of each layer in order.
+func (p Project) EnvHooks(layers []Layer) Hooks {
+	merged := MergeLayers(layers).Hooks
+	return Hooks{
+		PreRun:    append(append(StringList{}, p.Hooks.PreRun...), merged.PreRun...),
+		PostRun:   append(append(StringList{}, p.Hooks.PostRun...), merged.PostRun...),
+		OnFailure: append(append(StringList{}, p.Hooks.OnFailure...), merged.OnFailure...),
+	}
+}
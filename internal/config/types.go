@@ -1,19 +1,399 @@
 package config
 
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
 // Config represents the top-level menv configuration.
 type Config struct {
+	// Defaults applies to every env of every project, before anything else.
+	Defaults *Env               `yaml:"defaults,omitempty"`
 	Projects map[string]Project `yaml:"projects"`
 }
 
 // Project represents a single project entry.
 type Project struct {
-	Path    string         `yaml:"path"`
-	Command string         `yaml:"command"`
-	Envs    map[string]Env `yaml:"envs"`
+	Path    string `yaml:"path"`
+	Command string `yaml:"command"`
+	// Base applies to every env of the project, after the global defaults.
+	Base *Env           `yaml:"base,omitempty"`
+	Envs map[string]Env `yaml:"envs"`
+	// Schema declares the variables every env of the project must provide.
+	// Env-level schemas add to or replace its entries.
+	Schema map[string]SchemaField `yaml:"schema,omitempty"`
+	// Tasks are named commands run with 'menv run [project] <env> <task>'.
+	Tasks map[string]Task `yaml:"tasks,omitempty"`
+	// Hooks run around every command of the project, before those of the env.
+	Hooks Hooks `yaml:"hooks,omitempty"`
+	// Processes are started together by 'menv up [project] <env>'.
+	Processes map[string]Process `yaml:"processes,omitempty"`
+	// Shutdown controls how 'menv up' stops its processes.
+	Shutdown Shutdown `yaml:"shutdown,omitempty"`
+	// Restart applies to 'menv run' and to every process, unless a task or
+	// process has its own.
+	Restart *Restart `yaml:"restart,omitempty"`
+	// Watch lists glob patterns of files, relative to the project path, that
+	// 'menv run --watch' watches in addition to the env files and config.
+	Watch []string `yaml:"watch,omitempty"`
+}
+
+// Process is a long-running command started by 'menv up'. In YAML it is
+// either just the command, as in a Procfile, or a mapping:
+//
+//	processes:
+//	  api: go run ./cmd/api
+//	  web:
+//	    command: npm run dev
+//	    dir: web
+//	    overrides:
+//	      PORT: "3000"
+type Process struct {
+	Command string `yaml:"command"`
+	// Dir is the working directory, relative to the project path.
+	Dir string `yaml:"dir,omitempty"`
+	// Overrides are applied on top of the env's variables for this process.
+	Overrides map[string]string `yaml:"overrides,omitempty"`
+	// Restart replaces the project's restart settings for this process.
+	Restart *Restart `yaml:"restart,omitempty"`
+}
+
+// UnmarshalYAML accepts either a command or a mapping.
+func (p *Process) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		p.Command = node.Value
+		return nil
+	}
+	type plain Process
+	if err := node.Decode((*plain)(p)); err != nil {
+		return err
+	}
+	if p.Command == "" {
+		return fmt.Errorf("line %d: process is missing 'command'", node.Line)
+	}
+	return nil
+}
+
+// MarshalYAML writes processes with only a command back as a plain string.
+func (p Process) MarshalYAML() (any, error) {
+	if p.Dir == "" && len(p.Overrides) == 0 && p.Restart == nil {
+		return p.Command, nil
+	}
+	type plain Process
+	return plain(p), nil
+}
+
+// Restart says when a command is started again after it exits. In YAML it
+// is either just the policy or a mapping:
+//
+//	restart: on-failure
+//	restart:
+//	  policy: always
+//	  max_retries: 10
+//	  backoff: 2s
+type Restart struct {
+	// Policy is "never" (default), "on-failure" or "always".
+	Policy string `yaml:"policy"`
+	// MaxRetries limits the number of restarts; unlimited when 0.
+	MaxRetries int `yaml:"max_retries,omitempty"`
+	// Backoff is the delay before the first restart (1s when empty); it
+	// doubles after every quick crash, up to MaxBackoff (1m when empty).
+	Backoff    string `yaml:"backoff,omitempty"`
+	MaxBackoff string `yaml:"max_backoff,omitempty"`
+	// MinUptime is how long a run must last not to count as a quick crash
+	// (5s when empty). After FlapLimit quick crashes in a row (5 when 0)
+	// the command is not restarted again.
+	MinUptime string `yaml:"min_uptime,omitempty"`
+	FlapLimit int    `yaml:"flap_limit,omitempty"`
+}
+
+// UnmarshalYAML accepts either a policy name or a mapping.
+func (r *Restart) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		r.Policy = node.Value
+		return nil
+	}
+	type plain Restart
+	return node.Decode((*plain)(r))
+}
+
+// MarshalYAML writes settings with only a policy back as a plain string.
+func (r Restart) MarshalYAML() (any, error) {
+	if r == (Restart{Policy: r.Policy}) {
+		return r.Policy, nil
+	}
+	type plain Restart
+	return plain(r), nil
+}
+
+// Shutdown controls what 'menv up' does when one of its processes exits,
+// and how long commands get to stop, also when 'menv run --watch' restarts them.
+type Shutdown struct {
+	// Policy is "exit" (default) to stop the others when any process exits,
+	// "failure" to stop them only when one fails, or "never".
+	Policy string `yaml:"policy,omitempty"`
+	// GracePeriod is how long commands get to exit after SIGTERM before
+	// they are killed, as a duration such as "5s"; 10s when empty.
+	GracePeriod string `yaml:"grace_period,omitempty"`
+}
+
+// Hooks are shell commands run by 'menv run' around the command, in the
+// project directory and with the same environment. Each event takes a
+// single command or a list:
+//
+//	hooks:
+//	  pre_run: docker compose up -d db
+//	  post_run:
+//	    - docker compose stop db
+//	  on_failure: notify-send "menv: exit $MENV_EXIT_CODE"
+type Hooks struct {
+	// PreRun hooks run before the command; if one fails, the run is aborted.
+	PreRun StringList `yaml:"pre_run,omitempty"`
+	// PostRun hooks run after the command, whether or not it succeeded.
+	PostRun StringList `yaml:"post_run,omitempty"`
+	// OnFailure hooks run when the command fails, before PostRun.
+	OnFailure StringList `yaml:"on_failure,omitempty"`
+}
+
+// Task is a named command of a project. In YAML it is either just the
+// command or a mapping:
+//
+//	tasks:
+//	  serve: npm start
+//	  migrate:
+//	    command: npx prisma migrate deploy
+//	    description: Apply pending migrations
+//	    env: dev
+//	    dir: api
+//	    overrides:
+//	      LOG_LEVEL: warn
+type Task struct {
+	Command     string `yaml:"command"`
+	Description string `yaml:"description,omitempty"`
+	// Env is the env used when none is given on the command line.
+	Env string `yaml:"env,omitempty"`
+	// Dir is the working directory, relative to the project path.
+	Dir string `yaml:"dir,omitempty"`
+	// Overrides are applied on top of the env's variables, like a last layer.
+	Overrides map[string]string `yaml:"overrides,omitempty"`
+	// Restart replaces the project's restart settings for this task.
+	Restart *Restart `yaml:"restart,omitempty"`
+}
+
+// UnmarshalYAML accepts either a command or a mapping.
+func (t *Task) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		t.Command = node.Value
+		return nil
+	}
+	type plain Task
+	if err := node.Decode((*plain)(t)); err != nil {
+		return err
+	}
+	if t.Command == "" {
+		return fmt.Errorf("line %d: task is missing 'command'", node.Line)
+	}
+	return nil
+}
+
+// MarshalYAML writes tasks with only a command back as a plain string.
+func (t Task) MarshalYAML() (any, error) {
+	if t.Description == "" && t.Env == "" && t.Dir == "" && len(t.Overrides) == 0 && t.Restart == nil {
+		return t.Command, nil
+	}
+	type plain Task
+	return plain(t), nil
 }
 
 // Env represents an environment within a project.
 type Env struct {
-	Files     []string          `yaml:"files"`
+	// Extends names one or more envs of the same project whose files and
+	// overrides this env inherits; its own values take precedence.
+	Extends   StringList        `yaml:"extends,omitempty"`
+	Files     []FileRef         `yaml:"files"`
 	Overrides map[string]string `yaml:"overrides"`
+	// Strict makes loading fail on malformed env files instead of skipping
+	// the offending lines.
+	Strict bool `yaml:"strict,omitempty"`
+	// Pure starts commands from an empty environment instead of the OS one;
+	// only variables matching Inherit are passed through.
+	Pure bool `yaml:"pure,omitempty"`
+	// Inherit lists glob patterns of OS variables kept in pure mode.
+	Inherit []string `yaml:"inherit,omitempty"`
+	// Unset lists glob patterns of OS variables removed before running,
+	// in pure and non-pure mode alike.
+	Unset []string `yaml:"unset,omitempty"`
+	// Schema declares required and typed variables, see SchemaField.
+	Schema map[string]SchemaField `yaml:"schema,omitempty"`
+	// Secrets lists keys (or glob patterns) whose values are masked in
+	// output, in addition to the built-in patterns such as *_TOKEN.
+	Secrets []string `yaml:"secrets,omitempty"`
+	// Vault loads variables from a HashiCorp Vault KV v2 secret, after the
+	// env's files and before its overrides.
+	Vault *VaultSource `yaml:"vault,omitempty"`
+	// SSM loads variables from AWS Systems Manager Parameter Store, after
+	// Vault and before the env's overrides.
+	SSM *SSMSource `yaml:"ssm,omitempty"`
+	// Hooks run around commands started in this env, after the project's.
+	Hooks Hooks `yaml:"hooks,omitempty"`
+}
+
+// VaultSource names a secret in a Vault KV v2 engine:
+//
+//	vault:
+//	  address: https://vault.example.com:8200
+//	  mount: secret
+//	  path: my-api/prod
+//	  keys:
+//	    DB_PASSWORD: db_password
+type VaultSource struct {
+	// Address is the Vault server URL; $VAULT_ADDR when empty.
+	Address string `yaml:"address,omitempty"`
+	// Mount is the path the KV engine is mounted at; "secret" when empty.
+	Mount string `yaml:"mount,omitempty"`
+	Path  string `yaml:"path"`
+	// Keys maps env var names to fields of the secret. When empty, every
+	// field is loaded under its own name.
+	Keys map[string]string `yaml:"keys,omitempty"`
+	// TokenFile is read when $VAULT_TOKEN is not set; ~/.vault-token when empty.
+	TokenFile string `yaml:"token_file,omitempty"`
+}
+
+// SSMSource names a tree of parameters in AWS Systems Manager Parameter Store:
+//
+//	ssm:
+//	  path: /myapp/prod
+//	  region: eu-west-1
+//	  keys:
+//	    DATABASE_URL: db/url
+type SSMSource struct {
+	// Path is the parameter hierarchy to load, recursively.
+	Path string `yaml:"path"`
+	// Region and Profile default to AWS_REGION and AWS_PROFILE.
+	Region  string `yaml:"region,omitempty"`
+	Profile string `yaml:"profile,omitempty"`
+	// Endpoint overrides the service URL, e.g. for LocalStack.
+	Endpoint string `yaml:"endpoint,omitempty"`
+	// Keys maps env var names to parameter names relative to Path. When
+	// empty, every parameter is loaded, named after its path relative to
+	// Path with slashes replaced by underscores and upper-cased
+	// (db/password becomes DB_PASSWORD).
+	Keys map[string]string `yaml:"keys,omitempty"`
+}
+
+// SchemaField describes one variable in a schema. In YAML it is either a
+// mapping or just the type name, which declares an optional variable:
+//
+//	schema:
+//	  PORT: {type: int, required: true}
+//	  LOG_LEVEL: {type: enum, values: [debug, info, warn], default: info}
+//	  API_URL: url
+type SchemaField struct {
+	// Type is one of string (default), int, bool, url, duration, enum or regex.
+	Type     string `yaml:"type,omitempty"`
+	Required bool   `yaml:"required,omitempty"`
+	// Default is used when the variable is not set by any source.
+	Default *string `yaml:"default,omitempty"`
+	// Values lists the allowed values for enum.
+	Values []string `yaml:"values,omitempty"`
+	// Pattern is the regular expression a regex value must fully match.
+	Pattern     string `yaml:"pattern,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// UnmarshalYAML accepts either a type name or a mapping.
+func (f *SchemaField) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.Type = node.Value
+		return nil
+	}
+	type plain SchemaField
+	return node.Decode((*plain)(f))
+}
+
+// FileRef is an entry in Env.Files. In YAML it is either a plain path or a
+// mapping with extra options:
+//
+//	files:
+//	  - .env.dev
+//	  - path: config.dev.json
+//	    separator: "__"
+//	    case: upper
+type FileRef struct {
+	Path string `yaml:"path"`
+	// Format is one of "dotenv", "json", "yaml" or "toml". When empty it is
+	// detected from the file extension, defaulting to dotenv.
+	Format string `yaml:"format,omitempty"`
+	// Separator joins nested keys of structured files (default "_").
+	Separator string `yaml:"separator,omitempty"`
+	// Case is applied to flattened keys: "upper" (default), "lower" or "preserve".
+	Case string `yaml:"case,omitempty"`
+	// Encrypted marks a file written by 'menv secret encrypt'. Files ending
+	// in .enc are treated as encrypted without it.
+	Encrypted bool `yaml:"encrypted,omitempty"`
+	// Refs lets values of a dotenv file be references such as
+	// ref+exec://..., which are resolved when the env is loaded. It is off
+	// by default, so that a file from elsewhere cannot run commands.
+	Refs bool `yaml:"refs,omitempty"`
+}
+
+// UnmarshalYAML accepts either a scalar path or a mapping.
+func (f *FileRef) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		f.Path = node.Value
+		return nil
+	}
+	type plain FileRef
+	if err := node.Decode((*plain)(f)); err != nil {
+		return err
+	}
+	if f.Path == "" {
+		return fmt.Errorf("line %d: file entry is missing 'path'", node.Line)
+	}
+	return nil
+}
+
+// MarshalYAML writes entries without options back as plain paths.
+func (f FileRef) MarshalYAML() (any, error) {
+	if f == (FileRef{Path: f.Path}) {
+		return f.Path, nil
+	}
+	type plain FileRef
+	return plain(f), nil
+}
+
+// FilePaths returns the paths of refs, for display.
+func FilePaths(refs []FileRef) []string {
+	paths := make([]string, len(refs))
+	for i, f := range refs {
+		paths[i] = f.Path
+	}
+	return paths
+}
+
+// StringList is a list of strings that may be written in YAML as either a
+// single scalar or a sequence.
+type StringList []string
+
+// UnmarshalYAML accepts either a scalar or a sequence.
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	var items []string
+	if err := node.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// MarshalYAML writes single-element lists as a scalar.
+func (l StringList) MarshalYAML() (any, error) {
+	if len(l) == 1 {
+		return l[0], nil
+	}
+	return []string(l), nil
 }
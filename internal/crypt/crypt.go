@@ -0,0 +1,179 @@
+// Package crypt implements menv's encrypted file format.
+//
+// An encrypted file is a single header line followed by the base64-encoded
+// nonce and AES-256-GCM ciphertext:
+//
+//	menv-encrypted v1 key
+//	<base64>
+//
+// or, when the key is derived from a passphrase:
+//
+//	menv-encrypted v1 pbkdf2-sha256 <iterations> <base64 salt>
+//	<base64>
+//
+// The header line is authenticated as additional data, so it cannot be
+// altered without detection.
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	magic      = "menv-encrypted"
+	version    = "v1"
+	kdfKey     = "key"
+	kdfPBKDF2  = "pbkdf2-sha256"
+	keySize    = 32
+	saltSize   = 16
+	iterations = 600_000
+	lineWidth  = 76
+
+	// maxIterations bounds the iteration count Decrypt accepts, so that a
+	// crafted header cannot make it spin for hours.
+	maxIterations = 10_000_000
+)
+
+// ErrNotEncrypted is returned by Decrypt for data without a menv header.
+var ErrNotEncrypted = errors.New("not a menv encrypted file")
+
+// IsEncrypted reports whether data starts with a menv encryption header.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic+" "))
+}
+
+// Encrypt seals plaintext. If passphrase is true the key is derived from a
+// passphrase obtained from keys; otherwise the key file is used.
+func Encrypt(plaintext []byte, keys *Keys, passphrase bool) ([]byte, error) {
+	var header string
+	var key []byte
+
+	if passphrase {
+		pass, err := keys.passphrase(true)
+		if err != nil {
+			return nil, err
+		}
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		key, err = deriveKey(pass, salt, iterations)
+		if err != nil {
+			return nil, err
+		}
+		header = fmt.Sprintf("%s %s %s %d %s", magic, version, kdfPBKDF2, iterations, base64.StdEncoding.EncodeToString(salt))
+	} else {
+		var err error
+		key, err = keys.key()
+		if err != nil {
+			return nil, err
+		}
+		header = fmt.Sprintf("%s %s %s", magic, version, kdfKey)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(header))
+
+	var out bytes.Buffer
+	out.WriteString(header + "\n")
+	body := base64.StdEncoding.EncodeToString(sealed)
+	for len(body) > lineWidth {
+		out.WriteString(body[:lineWidth] + "\n")
+		body = body[lineWidth:]
+	}
+	out.WriteString(body + "\n")
+	return out.Bytes(), nil
+}
+
+// Decrypt opens data produced by Encrypt, obtaining the key or passphrase
+// named in its header from keys.
+func Decrypt(data []byte, keys *Keys) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return nil, ErrNotEncrypted
+	}
+
+	header, body, _ := bytes.Cut(data, []byte("\n"))
+	header = bytes.TrimRight(header, "\r")
+	fields := strings.Fields(string(header))
+	if len(fields) < 3 || fields[1] != version {
+		return nil, fmt.Errorf("unsupported menv encryption header %q", header)
+	}
+
+	var key []byte
+	switch fields[2] {
+	case kdfKey:
+		var err error
+		if key, err = keys.key(); err != nil {
+			return nil, err
+		}
+	case kdfPBKDF2:
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("malformed menv encryption header %q", header)
+		}
+		iter, err := strconv.Atoi(fields[3])
+		if err != nil || iter <= 0 || iter > maxIterations {
+			return nil, fmt.Errorf("malformed iteration count in header %q (expected 1 to %d)", header, maxIterations)
+		}
+		salt, err := base64.StdEncoding.DecodeString(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("malformed salt in header %q", header)
+		}
+		pass, err := keys.passphrase(false)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = deriveKey(pass, salt, iter); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key derivation %q", fields[2])
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted body: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted body is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed: wrong key or passphrase, or the file was modified")
+	}
+	return plaintext, nil
+}
+
+func deriveKey(passphrase string, salt []byte, iter int) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, salt, iter, keySize)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,202 @@
+package crypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKeys returns Keys with a freshly generated key file and passphrase.
+func testKeys(t *testing.T, passphrase string) *Keys {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := GenerateKey(path); err != nil {
+		t.Fatal(err)
+	}
+	return &Keys{KeyFile: path, Passphrase: passphrase}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Setenv("MENV_PASSPHRASE", "")
+	plaintext := []byte("DB_PASSWORD=hunter2\nAPI_KEY='x y z'\n" + strings.Repeat("long line ", 20) + "\n")
+
+	for _, passphrase := range []bool{false, true} {
+		keys := testKeys(t, "correct horse")
+		sealed, err := Encrypt(plaintext, keys, passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(sealed) {
+			t.Errorf("passphrase=%v: output has no header:\n%s", passphrase, sealed)
+		}
+		if bytes.Contains(sealed, []byte("hunter2")) {
+			t.Errorf("passphrase=%v: output contains the plaintext", passphrase)
+		}
+		header, body, _ := strings.Cut(string(sealed), "\n")
+		wantHeader := "menv-encrypted v1 key"
+		if passphrase {
+			wantHeader = "menv-encrypted v1 pbkdf2-sha256 600000 "
+		}
+		if !strings.HasPrefix(header, wantHeader) {
+			t.Errorf("header = %q, want %q", header, wantHeader)
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(body, "\n"), "\n") {
+			if len(line) > lineWidth {
+				t.Errorf("body line is %d characters long", len(line))
+			}
+		}
+
+		// A fresh Keys reads the key file or passphrase again.
+		got, err := Decrypt(sealed, &Keys{KeyFile: keys.KeyFile, Passphrase: keys.Passphrase})
+		if err != nil {
+			t.Fatalf("passphrase=%v: %v", passphrase, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("passphrase=%v: got %q, want %q", passphrase, got, plaintext)
+		}
+
+		// CRLF line endings, as after a checkout on Windows, still decrypt.
+		crlf := bytes.ReplaceAll(sealed, []byte("\n"), []byte("\r\n"))
+		if got, err := Decrypt(crlf, keys); err != nil || !bytes.Equal(got, plaintext) {
+			t.Errorf("passphrase=%v: CRLF file: %q, %v", passphrase, got, err)
+		}
+	}
+
+	// Encrypting twice gives different output (random nonce and salt).
+	keys := testKeys(t, "")
+	a, _ := Encrypt(plaintext, keys, false)
+	b, _ := Encrypt(plaintext, keys, false)
+	if bytes.Equal(a, b) {
+		t.Error("two encryptions are identical")
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	t.Setenv("MENV_PASSPHRASE", "")
+	keys := testKeys(t, "right")
+	byKey, err := Encrypt([]byte("A=1\n"), keys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPassphrase, err := Encrypt([]byte("A=1\n"), keys, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const want = "decryption failed: wrong key or passphrase, or the file was modified"
+	if _, err := Decrypt(byKey, testKeys(t, "")); err == nil || err.Error() != want {
+		t.Errorf("wrong key: error = %v", err)
+	}
+	if _, err := Decrypt(byPassphrase, testKeys(t, "wrong")); err == nil || err.Error() != want {
+		t.Errorf("wrong passphrase: error = %v", err)
+	}
+	// MENV_PASSPHRASE is used when no passphrase is configured.
+	t.Setenv("MENV_PASSPHRASE", "right")
+	if _, err := Decrypt(byPassphrase, &Keys{}); err != nil {
+		t.Errorf("MENV_PASSPHRASE: %v", err)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	t.Setenv("MENV_PASSPHRASE", "")
+	keys := testKeys(t, "pass")
+	for _, passphrase := range []bool{false, true} {
+		sealed, err := Encrypt([]byte("SECRET=value\n"), keys, passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, body, _ := strings.Cut(string(sealed), "\n")
+
+		raw, _ := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+		flipped := append([]byte{}, raw...)
+		flipped[len(flipped)-1] ^= 1
+
+		tests := map[string]string{
+			// The header is the GCM additional data, so any change to it,
+			// even one that parses the same, fails authentication.
+			"header":         header + " \n" + body,
+			"header spacing": strings.Replace(header, "menv-encrypted v1", "menv-encrypted v1 ", 1) + "\n" + body,
+			"body":           header + "\n" + base64.StdEncoding.EncodeToString(flipped) + "\n",
+			"truncated":      header + "\n" + base64.StdEncoding.EncodeToString(raw[:len(raw)-1]) + "\n",
+		}
+		for name, data := range tests {
+			_, err := Decrypt([]byte(data), keys)
+			if err == nil || !strings.Contains(err.Error(), "decryption failed") {
+				t.Errorf("passphrase=%v, %s: error = %v, want a decryption failure", passphrase, name, err)
+			}
+		}
+	}
+}
+
+func TestDecryptMalformed(t *testing.T) {
+	t.Setenv("MENV_PASSPHRASE", "")
+	keys := testKeys(t, "pass")
+	salt := base64.StdEncoding.EncodeToString(make([]byte, saltSize))
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"not encrypted", "A=1\n", "not a menv encrypted file"},
+		{"unknown version", "menv-encrypted v2 key\nAAAA\n", `unsupported menv encryption header "menv-encrypted v2 key"`},
+		{"missing kdf", "menv-encrypted v1\nAAAA\n", "unsupported menv encryption header"},
+		{"unknown kdf", "menv-encrypted v1 scrypt\nAAAA\n", `unsupported key derivation "scrypt"`},
+		{"missing salt", "menv-encrypted v1 pbkdf2-sha256 1000\nAAAA\n", "malformed menv encryption header"},
+		{"zero iterations", "menv-encrypted v1 pbkdf2-sha256 0 " + salt + "\nAAAA\n", "malformed iteration count"},
+		{"negative iterations", "menv-encrypted v1 pbkdf2-sha256 -5 " + salt + "\nAAAA\n", "malformed iteration count"},
+		{"too many iterations", "menv-encrypted v1 pbkdf2-sha256 2000000000 " + salt + "\nAAAA\n", "malformed iteration count"},
+		{"non-numeric iterations", "menv-encrypted v1 pbkdf2-sha256 many " + salt + "\nAAAA\n", "malformed iteration count"},
+		{"bad salt", "menv-encrypted v1 pbkdf2-sha256 1000 !!!\nAAAA\n", "malformed salt"},
+		{"bad body", "menv-encrypted v1 key\nnot base64!\n", "malformed encrypted body"},
+		{"short body", "menv-encrypted v1 key\nAAAA\n", "encrypted body is too short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decrypt([]byte(tt.data), keys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Decrypt([]byte("A=1\n"), keys); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("error = %v, want ErrNotEncrypted", err)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "key")
+	if err := GenerateKey(path); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("key file mode = %v, %v; want 0600", info.Mode(), err)
+	}
+	if err := GenerateKey(path); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("overwriting: error = %v", err)
+	}
+
+	bad := filepath.Join(dir, "bad")
+	os.WriteFile(bad, []byte(base64.StdEncoding.EncodeToString([]byte("too short"))), 0600)
+	tests := map[string]string{
+		filepath.Join(dir, "missing"): "not found (create one with 'menv secret keygen')",
+		bad:                           "does not contain a base64-encoded 32-byte key",
+	}
+	for path, want := range tests {
+		_, err := Encrypt([]byte("x"), &Keys{KeyFile: path}, false)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error = %v, want %q", filepath.Base(path), err, want)
+		}
+	}
+
+	t.Setenv("MENV_KEY_FILE", path)
+	if got := (&Keys{}).keyFile(); got != path {
+		t.Errorf("keyFile = %q, want $MENV_KEY_FILE", got)
+	}
+}
@@ -0,0 +1,148 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// Keys locates the key material used to encrypt and decrypt files and
+// caches it for the rest of the process, so a passphrase is asked for once.
+type Keys struct {
+	// KeyFile is the key file path; DefaultKeyFile() when empty.
+	KeyFile string
+	// Passphrase, when set, is used instead of $MENV_PASSPHRASE or a prompt.
+	Passphrase string
+
+	mu   sync.Mutex
+	k    []byte
+	pass string
+}
+
+// Default is the key source used when loading encrypted env files.
+var Default = &Keys{}
+
+// DefaultKeyFile returns $MENV_KEY_FILE, or menv/key in the user config
+// directory (e.g. ~/.config/menv/key).
+func DefaultKeyFile() string {
+	if p := os.Getenv("MENV_KEY_FILE"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "menv", "key")
+}
+
+func (k *Keys) keyFile() string {
+	if k.KeyFile != "" {
+		return k.KeyFile
+	}
+	return DefaultKeyFile()
+}
+
+// key reads and decodes the key file.
+func (k *Keys) key() ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.k != nil {
+		return k.k, nil
+	}
+
+	path := k.keyFile()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("key file %s not found (create one with 'menv secret keygen')", path)
+		}
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("key file %s does not contain a base64-encoded %d-byte key", path, keySize)
+	}
+	k.k = key
+	return key, nil
+}
+
+// passphrase returns the passphrase from the Passphrase field,
+// $MENV_PASSPHRASE, or an interactive prompt. When confirm is true the
+// prompt asks twice.
+func (k *Keys) passphrase(confirm bool) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.pass != "" {
+		return k.pass, nil
+	}
+
+	pass := k.Passphrase
+	if pass == "" {
+		pass = os.Getenv("MENV_PASSPHRASE")
+	}
+	if pass == "" {
+		var err error
+		if pass, err = prompt("Passphrase: "); err != nil {
+			return "", err
+		}
+		if confirm {
+			again, err := prompt("Confirm passphrase: ")
+			if err != nil {
+				return "", err
+			}
+			if again != pass {
+				return "", fmt.Errorf("passphrases do not match")
+			}
+		}
+	}
+	if pass == "" {
+		return "", fmt.Errorf("empty passphrase")
+	}
+	k.pass = pass
+	return pass, nil
+}
+
+// prompt reads a line from the terminal without echoing it.
+func prompt(label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("a passphrase is required: set MENV_PASSPHRASE or run interactively")
+	}
+	fmt.Fprint(os.Stderr, label)
+	b, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(b), nil
+}
+
+// GenerateKey writes a new random key to path, creating parent directories.
+// It refuses to overwrite an existing file.
+func GenerateKey(path string) error {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("key file %s already exists", path)
+		}
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, base64.StdEncoding.EncodeToString(key))
+	return err
+}
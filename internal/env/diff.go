@@ -0,0 +1,41 @@
+package env
+
+import "sort"
+
+// ChangeKind classifies a difference between two sets of variables.
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change is a single difference found by Diff. Old is empty for added keys
+// and New for removed ones.
+type Change struct {
+	Key  string
+	Kind ChangeKind
+	Old  string
+	New  string
+}
+
+// Diff compares the variables in a with those in b and returns the keys
+// that b adds, removes or changes, sorted by key.
+func Diff(a, b map[string]string) []Change {
+	var changes []Change
+	for k, old := range a {
+		if v, ok := b[k]; !ok {
+			changes = append(changes, Change{Key: k, Kind: Removed, Old: old})
+		} else if v != old {
+			changes = append(changes, Change{Key: k, Kind: Changed, Old: old, New: v})
+		}
+	}
+	for k, v := range b {
+		if _, ok := a[k]; !ok {
+			changes = append(changes, Change{Key: k, Kind: Added, New: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
@@ -0,0 +1,259 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+)
+
+// entry is a single key-value pair parsed from an env file.
+type entry struct {
+	key   string
+	value string
+	// line and endLine are the 1-based lines where the entry starts and ends;
+	// they differ only for multi-line quoted values.
+	line    int
+	endLine int
+	// quote is the quote character the value was wrapped in, or 0.
+	quote byte
+	// structured marks values read from JSON, YAML or TOML files.
+	structured bool
+}
+
+// literal reports whether the value must not be expanded.
+// Single-quoted, backtick-quoted and structured values are taken verbatim.
+func (e entry) literal() bool {
+	return e.quote == '\'' || e.quote == '`' || e.structured
+}
+
+// Issue is a problem found in an env file. Parsing is lenient, so issues are
+// only surfaced by lint and in strict mode.
+type Issue struct {
+	File    string
+	Line    int
+	Message string
+}
+
+// String formats the issue as "file:line: message".
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+}
+
+// parseDotenv parses dotenv-formatted text, following the syntax shared by
+// the Node and Ruby dotenv libraries:
+//   - KEY=VALUE and KEY: VALUE
+//   - export KEY=VALUE (strips the `export` prefix)
+//   - Comments (#), both on their own line and after a value
+//     (an unquoted value ends at whitespace followed by #)
+//   - Single-quoted values: taken literally, may span lines, no expansion
+//   - Double-quoted values: may span lines; \n, \r, \t, \", \' and \\
+//     are unescaped (\$, and backslashes before a $, are left for expand)
+//   - Backtick-quoted values: taken literally, may span lines
+//   - CRLF line endings and a leading UTF-8 BOM
+//
+// Lines without an assignment are skipped and reported as issues, along with
+// duplicate keys, keys that are not shell identifiers, unterminated quotes
+// and trailing whitespace.
+func parseDotenv(src string) ([]entry, []Issue) {
+	src = strings.TrimPrefix(src, "\ufeff")
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+
+	var result []entry
+	var issues []Issue
+	report := func(line int, format string, args ...any) {
+		issues = append(issues, Issue{Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	seen := make(map[string]int)
+	add := func(e entry) {
+		if first, dup := seen[e.key]; dup {
+			report(e.line, "duplicate key %q (first defined on line %d)", e.key, first)
+		} else {
+			seen[e.key] = e.line
+		}
+		if !IsIdentifier(e.key) {
+			report(e.line, "key %q is not a valid shell identifier", e.key)
+		}
+		result = append(result, e)
+	}
+
+	lineNo := 0
+	for pos := 0; pos < len(src); {
+		lineNo++
+		end := strings.IndexByte(src[pos:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += pos
+		}
+		line := src[pos:end]
+		next := end + 1
+
+		key, rest, ok := splitAssignment(line)
+		if !ok {
+			if body := strings.TrimSpace(line); body != "" && !strings.HasPrefix(body, "#") {
+				report(lineNo, "malformed line (expected KEY=VALUE)")
+			} else if body != "" && hasTrailingSpace(line) {
+				report(lineNo, "trailing whitespace")
+			}
+			pos = next
+			continue
+		}
+
+		e := entry{key: key, line: lineNo, endLine: lineNo}
+		valueStart := end - len(rest)
+
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'' || rest[0] == '`') {
+			q := rest[0]
+			if closing := findClosingQuote(src, valueStart+1, q); closing >= 0 {
+				raw := src[valueStart+1 : closing]
+				e.quote = q
+				e.value = raw
+				if q == '"' {
+					e.value = unescapeDouble(raw)
+				}
+				e.endLine = lineNo + strings.Count(raw, "\n")
+
+				// Anything after the closing quote up to the end of its line
+				// is treated as a comment.
+				lineEnd := strings.IndexByte(src[closing:], '\n')
+				if lineEnd < 0 {
+					lineEnd = len(src) - closing
+				}
+				next = closing + lineEnd + 1
+				tail := src[closing+1 : closing+lineEnd]
+				if t := strings.TrimSpace(tail); t != "" && !strings.HasPrefix(t, "#") {
+					report(e.endLine, "unexpected text after closing quote")
+				}
+				if hasTrailingSpace(tail) {
+					report(e.endLine, "trailing whitespace")
+				}
+				lineNo = e.endLine
+				add(e)
+				pos = next
+				continue
+			}
+			// Unterminated quote: fall back to an unquoted value.
+			report(lineNo, "unterminated %c quote", q)
+		}
+
+		if hasTrailingSpace(line) {
+			report(lineNo, "trailing whitespace")
+		}
+		e.value = stripInlineComment(rest)
+		add(e)
+		pos = next
+	}
+	return result, issues
+}
+
+// splitAssignment splits a line into its key and the (left-trimmed) text after
+// the separator. It reports false for blank lines, comments and lines that
+// contain no assignment.
+func splitAssignment(line string) (key, rest string, ok bool) {
+	body := strings.TrimLeft(line, " \t")
+	if body == "" || strings.HasPrefix(body, "#") {
+		return "", "", false
+	}
+
+	if strings.HasPrefix(body, "export ") || strings.HasPrefix(body, "export\t") {
+		body = strings.TrimLeft(body[len("export"):], " \t")
+	}
+
+	sep := strings.IndexByte(body, '=')
+	if colon := strings.Index(body, ": "); colon >= 0 && (sep < 0 || colon < sep) {
+		sep = colon
+	}
+	if sep < 0 {
+		return "", "", false
+	}
+
+	key = strings.TrimSpace(body[:sep])
+	if key == "" {
+		return "", "", false
+	}
+
+	// rest is a suffix of line, so the caller can locate the value in the source.
+	return key, strings.TrimLeft(body[sep+1:], " \t"), true
+}
+
+// findClosingQuote returns the index of the quote q that closes a value
+// starting at src[start], or -1 if the value is unterminated. Inside double
+// quotes a backslash escapes the following character.
+func findClosingQuote(src string, start int, q byte) int {
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if q == '"' {
+				i++
+			}
+		case q:
+			return i
+		}
+	}
+	return -1
+}
+
+// stripInlineComment removes a trailing " # comment" from an unquoted value
+// and trims surrounding whitespace.
+func stripInlineComment(v string) string {
+	if strings.HasPrefix(v, "#") {
+		return ""
+	}
+	for i := 1; i < len(v); i++ {
+		if v[i] == '#' && (v[i-1] == ' ' || v[i-1] == '\t') {
+			v = v[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(v)
+}
+
+// unescapeDouble processes backslash escapes inside a double-quoted value.
+func unescapeDouble(s string) string {
+	if !strings.ContainsRune(s, '\\') {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		// A run of backslashes before a $ is left for expand, which decides
+		// whether the $ is escaped.
+		n := 1
+		for i+n < len(s) && s[i+n] == '\\' {
+			n++
+		}
+		if i+n < len(s) && s[i+n] == '$' {
+			b.WriteString(s[i : i+n])
+			i += n - 1
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '"', '\'', '\\':
+			b.WriteByte(s[i])
+		default:
+			// Unknown escapes are kept as written.
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// IsIdentifier reports whether key is a valid POSIX shell variable name.
+func IsIdentifier(key string) bool {
+	return key != "" && nameLen(key) == len(key)
+}
+
+// hasTrailingSpace reports whether line ends in a space or tab.
+func hasTrailingSpace(line string) bool {
+	return strings.HasSuffix(line, " ") || strings.HasSuffix(line, "\t")
+}
@@ -0,0 +1,227 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/akpatel363/menv/internal/config"
+)
+
+// Edit is a change to a single key of a dotenv file.
+type Edit struct {
+	Key   string
+	Value string
+	// Unset removes the key instead of setting it.
+	Unset bool
+}
+
+// EditDotenv applies edits, in order, to the dotenv file ref of project.
+// Everything else in the file is kept as it was: comments, blank lines,
+// ordering, `export` prefixes, inline comments and the line ending of each
+// line. A changed value keeps its quoting style unless the new value cannot
+// be written in it. New keys are appended, with the file's last line ending.
+//
+// Before the file is rewritten it is copied to <file>.bak. A missing file is
+// created. Unsetting a key the file does not define is an error, and leaves
+// the file untouched.
+func EditDotenv(project config.Project, ref config.FileRef, edits []Edit) error {
+	path := resolvePath(project, ref)
+	if format, err := fileFormat(ref); err != nil {
+		return err
+	} else if format != formatDotenv {
+		return fmt.Errorf("%s: only dotenv files can be edited, not %s", ref.Path, format)
+	}
+	if ref.Encrypted || strings.HasSuffix(ref.Path, ".enc") {
+		return fmt.Errorf("%s: encrypted files cannot be edited in place", ref.Path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	exists := err == nil
+	if isSOPSDotenv(data) {
+		return fmt.Errorf("%s: SOPS files cannot be edited in place (use 'sops edit')", ref.Path)
+	}
+
+	src := string(data)
+	bom := strings.HasPrefix(src, "\ufeff")
+	src = strings.TrimPrefix(src, "\ufeff")
+	eol := lineEnding(src)
+
+	var missing []string
+	for _, e := range edits {
+		var found bool
+		if e.Unset {
+			src, found = unsetKey(src, e.Key)
+			if !found {
+				missing = append(missing, e.Key)
+			}
+			continue
+		}
+		if src, found = setKey(src, e.Key, e.Value); !found {
+			if src != "" && !strings.HasSuffix(src, "\n") {
+				src += eol
+			}
+			src += strings.ReplaceAll(e.Key+"="+quoteValue(e.Value, 0), "\n", eol) + eol
+		}
+	}
+
+	if bom {
+		src = "\ufeff" + src
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: %s not set", ref.Path, strings.Join(missing, ", "))
+	}
+	if exists && src == string(data) {
+		return nil
+	}
+
+	mode := os.FileMode(0644)
+	if exists {
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+		if err := os.WriteFile(path+".bak", data, mode); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(src), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// setKey replaces the value of the last definition of key in src, which is
+// the one that takes effect. It reports false if key is not defined.
+func setKey(src, key, value string) (string, bool) {
+	entries, _ := parseDotenv(src)
+	var target *entry
+	for i := range entries {
+		if entries[i].key == key {
+			target = &entries[i]
+		}
+	}
+	if target == nil {
+		return src, false
+	}
+
+	// parseDotenv reads CRLF as LF, so strip the CRs of the entry's lines
+	// and give the replacement the ending of its last line.
+	lines := strings.Split(src, "\n")
+	crlf := strings.HasSuffix(lines[target.endLine-1], "\r")
+	for i := target.line - 1; i < target.endLine; i++ {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	first := lines[target.line-1]
+	_, rest, _ := splitAssignment(first)
+	prefix := first[:len(first)-len(rest)]
+
+	// tail is whatever followed the value on its last line, such as an
+	// inline comment.
+	var tail string
+	if target.quote != 0 {
+		joined := strings.Join(lines[target.line-1:target.endLine], "\n")
+		closing := findClosingQuote(joined, len(prefix)+1, target.quote)
+		tail = joined[closing+1:]
+	} else {
+		tail = inlineComment(rest)
+		if strings.HasPrefix(tail, "#") {
+			tail = " " + tail
+		}
+	}
+
+	replacement := prefix + quoteValue(value, target.quote) + tail
+	if crlf {
+		replacement = strings.ReplaceAll(replacement, "\n", "\r\n") + "\r"
+	}
+	lines = append(lines[:target.line-1], append([]string{replacement}, lines[target.endLine:]...)...)
+	return strings.Join(lines, "\n"), true
+}
+
+// unsetKey removes every definition of key from src, reporting whether
+// there was one.
+func unsetKey(src, key string) (string, bool) {
+	entries, _ := parseDotenv(src)
+	lines := strings.Split(src, "\n")
+	found := false
+	// Remove from the bottom up so earlier line numbers stay valid.
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if e.key != key {
+			continue
+		}
+		found = true
+		lines = append(lines[:e.line-1], lines[e.endLine:]...)
+	}
+	return strings.Join(lines, "\n"), found
+}
+
+// lineEnding returns the ending of the last complete line of src, "\r\n" or
+// "\n", and "\n" if there is none.
+func lineEnding(src string) string {
+	if i := strings.LastIndexByte(src, '\n'); i > 0 && src[i-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// inlineComment returns the trailing " # comment" of an unquoted value,
+// including the whitespace before it, or "".
+func inlineComment(rest string) string {
+	if strings.HasPrefix(rest, "#") {
+		return rest
+	}
+	for i := 1; i < len(rest); i++ {
+		if rest[i] == '#' && (rest[i-1] == ' ' || rest[i-1] == '\t') {
+			return rest[len(strings.TrimRight(rest[:i], " \t")):]
+		}
+	}
+	return rest[len(strings.TrimRight(rest, " \t")):]
+}
+
+// quoteValue formats value for a dotenv file, in the quote style q if it
+// can hold the value, otherwise unquoted when that is unambiguous, and in
+// double quotes as a last resort. A value that was quoted literally stays
+// literal: if it contains its quote, it is quoted as dotenvQuote would.
+func quoteValue(value string, q byte) string {
+	switch q {
+	case '\'', '`':
+		if strings.ContainsRune(value, rune(q)) {
+			return dotenvQuote(value)
+		}
+		return string(q) + value + string(q)
+	case '"':
+		return doubleQuote(value)
+	}
+	if needsQuotes(value) {
+		return doubleQuote(value)
+	}
+	return value
+}
+
+// needsQuotes reports whether value would be read back differently if
+// written unquoted, or contains whitespace, which most tools that source
+// dotenv files as shell scripts would split.
+func needsQuotes(value string) bool {
+	if value == "" {
+		return false
+	}
+	if strings.ContainsAny(value, " \t\n\r") {
+		return true
+	}
+	switch value[0] {
+	case '"', '\'', '`', '#':
+		return true
+	}
+	return false
+}
+
+// doubleQuote wraps value in double quotes, escaping what unescapeDouble
+// would otherwise interpret. $ is left as is, so references still expand.
+func doubleQuote(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(value) + `"`
+}
@@ -0,0 +1,132 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akpatel363/menv/internal/config"
+)
+
+func TestEditDotenv(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		edits []Edit
+		want  string
+	}{
+		{
+			name:  "keeps comments and inline comments",
+			src:   "# db\nexport HOST=old # primary\nPORT=1\n",
+			edits: []Edit{{Key: "HOST", Value: "new"}},
+			want:  "# db\nexport HOST=new # primary\nPORT=1\n",
+		},
+		{
+			name:  "appends new keys",
+			src:   "A=1",
+			edits: []Edit{{Key: "B", Value: "two words"}},
+			want:  "A=1\nB=\"two words\"\n",
+		},
+		{
+			name:  "crlf file",
+			src:   "A=1\r\nB=2\r\n",
+			edits: []Edit{{Key: "A", Value: "x"}, {Key: "C", Value: "3"}},
+			want:  "A=x\r\nB=2\r\nC=3\r\n",
+		},
+		{
+			name:  "mixed endings are kept per line",
+			src:   "A=1\r\nB=2\nC=3\r\nD=4\n",
+			edits: []Edit{{Key: "B", Value: "x"}, {Key: "C", Value: "y"}, {Key: "E", Value: "5"}},
+			want:  "A=1\r\nB=x\nC=y\r\nD=4\nE=5\n",
+		},
+		{
+			name:  "multi-line value in a crlf file",
+			src:   "A='line1\r\nline2'\r\nB=2\r\n",
+			edits: []Edit{{Key: "A", Value: "one\ntwo\nthree"}},
+			want:  "A='one\r\ntwo\r\nthree'\r\nB=2\r\n",
+		},
+		{
+			name:  "unset keeps other endings",
+			src:   "A=1\r\nB=2\nC=3\r\n",
+			edits: []Edit{{Key: "B", Unset: true}},
+			want:  "A=1\r\nC=3\r\n",
+		},
+		{
+			name:  "single quotes are kept",
+			src:   "A='${X}'\n",
+			edits: []Edit{{Key: "A", Value: "$HOME/bin"}},
+			want:  "A='$HOME/bin'\n",
+		},
+		{
+			name:  "single-quoted value with a quote stays literal",
+			src:   "A='x'\n",
+			edits: []Edit{{Key: "A", Value: "it's $HOME"}},
+			want:  "A=\"it's \\$HOME\"\n",
+		},
+		{
+			name:  "backquoted value with a backquote stays literal",
+			src:   "A=`x`\n",
+			edits: []Edit{{Key: "A", Value: "run `cmd` for $HOME"}},
+			want:  "A='run `cmd` for $HOME'\n",
+		},
+		{
+			name:  "double quotes escape",
+			src:   "A=\"x\" # note\n",
+			edits: []Edit{{Key: "A", Value: `say "hi"` + "\n"}},
+			want:  "A=\"say \\\"hi\\\"\\n\" # note\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, ".env")
+			if err := os.WriteFile(path, []byte(tt.src), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := EditDotenv(config.Project{Path: dir}, config.FileRef{Path: ".env"}, tt.edits); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+			if bak, _ := os.ReadFile(path + ".bak"); string(bak) != tt.src {
+				t.Errorf("backup = %q, want the original file", bak)
+			}
+
+			// Every set value reads back as written.
+			entries, _ := parseDotenv(string(got))
+			for _, e := range tt.edits {
+				if e.Unset {
+					continue
+				}
+				for _, en := range entries {
+					if en.key != e.Key {
+						continue
+					}
+					value := en.value
+					if !en.literal() {
+						value, _ = expand(value, func(string) (string, bool) { return "", false })
+					}
+					if value != e.Value {
+						t.Errorf("%s reads back as %q, want %q", e.Key, value, e.Value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEditDotenvMissingKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	os.WriteFile(path, []byte("A=1\n"), 0600)
+
+	err := EditDotenv(config.Project{Path: dir}, config.FileRef{Path: ".env"}, []Edit{{Key: "A", Unset: true}, {Key: "B", Unset: true}})
+	if err == nil || err.Error() != ".env: B not set" {
+		t.Errorf("error = %v, want B not set", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "A=1\n" {
+		t.Errorf("file changed to %q", got)
+	}
+}
@@ -0,0 +1,160 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lookupFunc resolves a variable name to its value during expansion.
+// The boolean reports whether the variable is set at all.
+type lookupFunc func(name string) (string, bool)
+
+// expand performs shell-style variable expansion on s.
+// Supports:
+//   - $VAR and ${VAR}
+//   - ${VAR:-default} (default when VAR is unset or empty)
+//   - ${VAR-default}  (default when VAR is unset)
+//   - ${VAR:?message} (error when VAR is unset or empty)
+//   - ${VAR?message}  (error when VAR is unset)
+//   - \$ for a literal dollar sign, and \\ for a backslash before a $
+//
+// Defaults and messages are themselves expanded. Unset variables without a
+// default expand to the empty string.
+func expand(s string, lookup lookupFunc) (string, error) {
+	if !strings.ContainsRune(s, '$') {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c == '\\' {
+			// Backslashes escape each other and a following $; elsewhere
+			// they are kept as written.
+			n := 1
+			for i+n < len(s) && s[i+n] == '\\' {
+				n++
+			}
+			if i+n >= len(s) || s[i+n] != '$' {
+				b.WriteString(s[i : i+n])
+				i += n - 1
+				continue
+			}
+			b.WriteString(strings.Repeat(`\`, n/2))
+			i += n - 1
+			if n%2 == 1 {
+				b.WriteByte('$')
+				i++
+			}
+			continue
+		}
+		if c != '$' || i+1 >= len(s) {
+			b.WriteByte(c)
+			continue
+		}
+
+		if s[i+1] == '{' {
+			end := matchBrace(s, i+1)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			v, err := expandBraced(s[i+2:end], lookup)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(v)
+			i = end
+			continue
+		}
+
+		n := nameLen(s[i+1:])
+		if n == 0 {
+			b.WriteByte(c)
+			continue
+		}
+		v, _ := lookup(s[i+1 : i+1+n])
+		b.WriteString(v)
+		i += n
+	}
+	return b.String(), nil
+}
+
+// expandBraced expands the inside of a ${...} expression.
+func expandBraced(expr string, lookup lookupFunc) (string, error) {
+	n := nameLen(expr)
+	if n == 0 {
+		return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+	}
+	name, rest := expr[:n], expr[n:]
+	value, set := lookup(name)
+
+	if rest == "" {
+		return value, nil
+	}
+
+	// The colon forms also treat an empty value as unset.
+	colon := strings.HasPrefix(rest, ":")
+	if colon {
+		rest = rest[1:]
+		set = set && value != ""
+	}
+	if rest == "" {
+		return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+	}
+
+	op, arg := rest[0], rest[1:]
+	switch op {
+	case '-':
+		if set {
+			return value, nil
+		}
+		return expand(arg, lookup)
+	case '?':
+		if set {
+			return value, nil
+		}
+		msg, err := expand(arg, lookup)
+		if err != nil {
+			return "", err
+		}
+		if msg == "" {
+			msg = "required but not set"
+		}
+		return "", fmt.Errorf("%s: %s", name, msg)
+	default:
+		return "", fmt.Errorf("unsupported operator in ${%s}", expr)
+	}
+}
+
+// matchBrace returns the index of the } closing the { at s[open],
+// accounting for nested ${...} expressions, or -1 if there is none.
+func matchBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// nameLen returns the length of the variable name at the start of s.
+func nameLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return i
+	}
+	return len(s)
+}
@@ -0,0 +1,137 @@
+package env
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{
+		"HOST":  "db.local",
+		"USER":  "admin",
+		"EMPTY": "",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no references", "plain value", "plain value"},
+		{"bare", "$HOST", "db.local"},
+		{"braced", "postgres://${USER}@${HOST}/app", "postgres://admin@db.local/app"},
+		{"bare name ends at non-identifier", "$HOST:5432", "db.local:5432"},
+		{"unset expands to empty", "a${MISSING}b", "ab"},
+		{"lone dollar", "cost: $", "cost: $"},
+		{"dollar before non-name", "$1 and $-", "$1 and $-"},
+		{"escaped dollar", `\$HOST`, "$HOST"},
+		{"colon default when unset", "${MISSING:-info}", "info"},
+		{"colon default when empty", "${EMPTY:-info}", "info"},
+		{"colon default when set", "${HOST:-info}", "db.local"},
+		{"default when unset", "${MISSING-info}", "info"},
+		{"no default when empty", "${EMPTY-info}", ""},
+		{"default is expanded", "${MISSING:-${USER}@$HOST}", "admin@db.local"},
+		{"nested defaults", "${A:-${B:-deep}}", "deep"},
+		{"required when set", "${HOST:?HOST is required}", "db.local"},
+		{"required when empty without colon", "${EMPTY?must be set}", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expand(tt.in, lookup)
+			if err != nil {
+				t.Fatalf("expand(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("expand(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "EMPTY" {
+			return "", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"required message", "${API_KEY:?API_KEY is required}", "API_KEY: API_KEY is required"},
+		{"required when empty", "${EMPTY:?no value}", "EMPTY: no value"},
+		{"required default message", "${API_KEY?}", "API_KEY: required but not set"},
+		{"message is expanded", "${API_KEY:?set ${EMPTY:-it}}", "API_KEY: set it"},
+		{"unterminated", "${HOST", "unterminated ${"},
+		{"invalid name", "${1A}", "invalid variable reference"},
+		{"unsupported operator", "${HOST:+alt}", "unsupported operator"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expand(tt.in, lookup)
+			if err == nil {
+				t.Fatalf("expand(%q) = %q, want error containing %q", tt.in, got, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expand(%q) error = %q, want it to contain %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandOverrides(t *testing.T) {
+	base := Vars{}
+	base.set("PATH", "/usr/bin", Source{Kind: SourceFile}, nil)
+	base.set("DB_HOST", "file-host", Source{Kind: SourceFile}, nil)
+
+	got, err := expandOverrides(map[string]string{
+		"URL":     "postgres://${DB_HOST}:${DB_PORT}/app",
+		"DB_PORT": "5432",
+		"DB_HOST": "override-host",
+		"PATH":    "${PATH}:/opt/bin",
+	}, base, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"URL":     "postgres://override-host:5432/app",
+		"DB_PORT": "5432",
+		"DB_HOST": "override-host",
+		"PATH":    "/usr/bin:/opt/bin",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestExpandOverridesCycle(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		want      string
+	}{
+		{"two keys", map[string]string{"A": "${B}", "B": "${A}"}, "A -> B -> A"},
+		{"three keys", map[string]string{"A": "x${B}", "B": "${C:-c}", "C": "$A"}, "A -> B -> C -> A"},
+		{"through a default", map[string]string{"A": "${MISSING:-$B}", "B": "$A"}, "A -> B -> A"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := expandOverrides(tt.overrides, Vars{}, nil, "")
+			if err == nil {
+				t.Fatal("expected a reference cycle error")
+			}
+			if !strings.Contains(err.Error(), "variable reference cycle: "+tt.want) {
+				t.Errorf("error = %q, want cycle %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package env
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/akpatel363/menv/internal/provider"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExportFormats lists the formats accepted by Export.
+var ExportFormats = []string{"posix", "dotenv", "json", "yaml", "docker", "systemd", "github"}
+
+// bareValue matches values that need no quoting in any format.
+var bareValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+
+// Export writes the variables named by keys, in that order, to w:
+//
+//   - posix:   export K='v' for eval in sh, bash and zsh
+//   - dotenv:  K=v, quoted so that menv reads back exactly v
+//   - json:    a single JSON object
+//   - yaml:    a YAML mapping
+//   - docker:  K=v, unquoted, for docker run --env-file
+//   - systemd: K="v", for a unit's EnvironmentFile=
+//   - github:  K=v, or a heredoc for multi-line values, for $GITHUB_ENV
+func Export(w io.Writer, format string, keys []string, values map[string]string) error {
+	switch format {
+	case "json", "yaml":
+		m := make(map[string]string, len(keys))
+		for _, k := range keys {
+			m[k] = values[k]
+		}
+		var out []byte
+		var err error
+		if format == "json" {
+			out, err = json.MarshalIndent(m, "", "  ")
+			out = append(out, '\n')
+		} else {
+			out, err = yaml.Marshal(m)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	}
+
+	var line func(k, v string) (string, error)
+	switch format {
+	case "posix":
+		line = func(k, v string) (string, error) {
+			return "export " + k + "=" + shellQuote(v), nil
+		}
+	case "dotenv":
+		line = func(k, v string) (string, error) {
+			return k + "=" + dotenvQuote(v), nil
+		}
+	case "docker":
+		line = func(k, v string) (string, error) {
+			if strings.ContainsAny(v, "\n\r") {
+				return "", fmt.Errorf("%s: docker env files cannot hold multi-line values", k)
+			}
+			return k + "=" + v, nil
+		}
+	case "systemd":
+		line = func(k, v string) (string, error) {
+			r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+			return k + `="` + r.Replace(v) + `"`, nil
+		}
+	case "github":
+		line = githubLine
+	default:
+		return fmt.Errorf("unknown format %q (expected one of: %s)", format, strings.Join(ExportFormats, ", "))
+	}
+
+	// Shells, docker and systemd only accept identifiers as names.
+	identifiers := format == "posix" || format == "docker" || format == "systemd"
+
+	var b strings.Builder
+	for _, k := range keys {
+		if identifiers && !IsIdentifier(k) {
+			return fmt.Errorf("key %q is not a valid shell identifier and cannot be written in %s format", k, format)
+		}
+		l, err := line(k, values[k])
+		if err != nil {
+			return err
+		}
+		b.WriteString(l + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// shellQuote quotes s for POSIX shells. Inside single quotes nothing is
+// special, so only single quotes themselves need care: each one closes the
+// quoted string, adds an escaped quote and reopens it.
+func shellQuote(s string) string {
+	if s != "" && bareValue.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dotenvQuote quotes s so that parseDotenv and expand return it unchanged:
+// single quotes when possible, since they are taken literally, otherwise
+// double quotes with escapes, including \$ to prevent expansion. Values
+// that would be read as a reference are never left bare, and in double
+// quotes get the extra backslash that unescapeRef removes.
+func dotenvQuote(s string) string {
+	ref := unescapeRef(s) != s || provider.IsRef(s)
+	if bareValue.MatchString(s) && !ref {
+		return s
+	}
+	if !strings.ContainsAny(s, "'\r") {
+		return "'" + s + "'"
+	}
+	if ref {
+		s = `\` + s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// githubLine formats a variable for $GITHUB_ENV. Multi-line values use the
+// heredoc syntax with a random delimiter that does not occur in the value.
+func githubLine(k, v string) (string, error) {
+	if !strings.ContainsAny(v, "\n\r") {
+		return k + "=" + v, nil
+	}
+	for {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		delim := "ghadelimiter_" + hex.EncodeToString(b)
+		if !strings.Contains(v, delim) {
+			return k + "<<" + delim + "\n" + v + "\n" + delim, nil
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package env
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/akpatel363/menv/internal/provider"
+
+	"gopkg.in/yaml.v3"
+)
+
+// exportValues exercises the quoting of every format.
+var exportValues = map[string]string{
+	"PLAIN":  "abc",
+	"EMPTY":  "",
+	"QUOTES": `it's "x"`,
+	"MULTI":  "a\nb",
+	"DOLLAR": "$HOME and ${USER}",
+	"BACK":   `C:\dir\`,
+	"TICK":   "`cmd`",
+	"REF":    "ref+vault://secret/db#password",
+	"REFQ":   "ref+echo://it's",
+	"CR":     "a\r\nb",
+}
+
+func TestExportLines(t *testing.T) {
+	keys := []string{"PLAIN", "EMPTY", "QUOTES", "MULTI", "DOLLAR", "BACK", "TICK", "REF", "REFQ"}
+	tests := []struct {
+		format string
+		keys   []string
+		want   string
+	}{
+		{
+			format: "posix",
+			want: "export PLAIN=abc\n" +
+				"export EMPTY=''\n" +
+				`export QUOTES='it'\''s "x"'` + "\n" +
+				"export MULTI='a\nb'\n" +
+				"export DOLLAR='$HOME and ${USER}'\n" +
+				`export BACK='C:\dir\'` + "\n" +
+				"export TICK='`cmd`'\n" +
+				"export REF='ref+vault://secret/db#password'\n" +
+				`export REFQ='ref+echo://it'\''s'` + "\n",
+		},
+		{
+			format: "dotenv",
+			want: "PLAIN=abc\n" +
+				"EMPTY=\n" +
+				`QUOTES="it's \"x\""` + "\n" +
+				"MULTI='a\nb'\n" +
+				"DOLLAR='$HOME and ${USER}'\n" +
+				`BACK='C:\dir\'` + "\n" +
+				"TICK='`cmd`'\n" +
+				"REF='ref+vault://secret/db#password'\n" +
+				`REFQ="\\ref+echo://it's"` + "\n",
+		},
+		{
+			format: "docker",
+			keys:   []string{"PLAIN", "EMPTY", "QUOTES", "DOLLAR", "BACK", "TICK", "REF"},
+			want: "PLAIN=abc\n" +
+				"EMPTY=\n" +
+				`QUOTES=it's "x"` + "\n" +
+				"DOLLAR=$HOME and ${USER}\n" +
+				`BACK=C:\dir\` + "\n" +
+				"TICK=`cmd`\n" +
+				"REF=ref+vault://secret/db#password\n",
+		},
+		{
+			format: "systemd",
+			want: `PLAIN="abc"` + "\n" +
+				`EMPTY=""` + "\n" +
+				`QUOTES="it's \"x\""` + "\n" +
+				"MULTI=\"a\nb\"\n" +
+				`DOLLAR="\$HOME and \${USER}"` + "\n" +
+				`BACK="C:\\dir\\"` + "\n" +
+				"TICK=\"\\`cmd\\`\"\n" +
+				`REF="ref+vault://secret/db#password"` + "\n" +
+				`REFQ="ref+echo://it's"` + "\n",
+		},
+		{
+			format: "github",
+			keys:   []string{"PLAIN", "EMPTY", "QUOTES", "DOLLAR", "BACK", "TICK", "REF"},
+			want: "PLAIN=abc\n" +
+				"EMPTY=\n" +
+				`QUOTES=it's "x"` + "\n" +
+				"DOLLAR=$HOME and ${USER}\n" +
+				`BACK=C:\dir\` + "\n" +
+				"TICK=`cmd`\n" +
+				"REF=ref+vault://secret/db#password\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			k := keys
+			if tt.keys != nil {
+				k = tt.keys
+			}
+			var b strings.Builder
+			if err := Export(&b, tt.format, k, exportValues); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+// TestExportDotenvRoundTrip reads dotenv output back the way LoadEnv does,
+// with references enabled, and expects the original values.
+func TestExportDotenvRoundTrip(t *testing.T) {
+	keys := sortedKeys(exportValues)
+	var b strings.Builder
+	if err := Export(&b, "dotenv", keys, exportValues); err != nil {
+		t.Fatal(err)
+	}
+	entries, issues := parseDotenv(b.String())
+	if len(issues) > 0 {
+		t.Errorf("issues: %v", issues)
+	}
+	if len(entries) != len(keys) {
+		t.Fatalf("got %d entries from\n%s", len(entries), b.String())
+	}
+	for _, e := range entries {
+		value := e.value
+		if !e.literal() {
+			if provider.IsRef(value) {
+				t.Errorf("%s would be resolved as a reference: %s", e.key, value)
+				continue
+			}
+			var err error
+			if value, err = expand(unescapeRef(value), func(string) (string, bool) { return "expanded", true }); err != nil {
+				t.Fatalf("%s: %v", e.key, err)
+			}
+		}
+		if value != exportValues[e.key] {
+			t.Errorf("%s = %q, want %q", e.key, value, exportValues[e.key])
+		}
+	}
+}
+
+func TestExportStructured(t *testing.T) {
+	keys := []string{"QUOTES", "MULTI", "CR", "BACK", "REF", "EMPTY"}
+	for _, format := range []string{"json", "yaml"} {
+		var b strings.Builder
+		if err := Export(&b, format, keys, exportValues); err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]string
+		var err error
+		if format == "json" {
+			err = json.Unmarshal([]byte(b.String()), &got)
+		} else {
+			err = yaml.Unmarshal([]byte(b.String()), &got)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", format, err, b.String())
+		}
+		if len(got) != len(keys) {
+			t.Errorf("%s: got %d keys, want %d", format, len(got), len(keys))
+		}
+		for _, k := range keys {
+			if got[k] != exportValues[k] {
+				t.Errorf("%s: %s = %q, want %q", format, k, got[k], exportValues[k])
+			}
+		}
+	}
+}
+
+func TestExportGithubHeredoc(t *testing.T) {
+	heredoc := regexp.MustCompile(`^MULTI<<(ghadelimiter_[0-9a-f]{16})\n([^\x00]*)\n(ghadelimiter_[0-9a-f]{16})\n$`)
+	for _, value := range []string{"a\nb", "a\r\nb", "ends with newline\n", "ghadelimiter_\nlooks like one"} {
+		var b strings.Builder
+		if err := Export(&b, "github", []string{"MULTI"}, map[string]string{"MULTI": value}); err != nil {
+			t.Fatal(err)
+		}
+		m := heredoc.FindStringSubmatch(b.String())
+		if m == nil {
+			t.Errorf("%q: not a heredoc:\n%s", value, b.String())
+			continue
+		}
+		if m[1] != m[3] || m[2] != value {
+			t.Errorf("%q: got delimiters %s/%s and value %q", value, m[1], m[3], m[2])
+		}
+		if strings.Contains(value, m[1]) {
+			t.Errorf("%q: delimiter %s occurs in the value", value, m[1])
+		}
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	values := map[string]string{"MULTI": "a\nb", "MY-KEY": "x", "1ST": "x", "a.b": "x"}
+	tests := []struct {
+		format string
+		keys   []string
+		want   string
+	}{
+		{"docker", []string{"MULTI"}, "MULTI: docker env files cannot hold multi-line values"},
+		{"posix", []string{"MY-KEY"}, `key "MY-KEY" is not a valid shell identifier`},
+		{"docker", []string{"1ST"}, `key "1ST" is not a valid shell identifier`},
+		{"systemd", []string{"a.b"}, `key "a.b" is not a valid shell identifier`},
+		{"xml", []string{"MULTI"}, `unknown format "xml"`},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		err := Export(&b, tt.format, tt.keys, values)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %v: error = %v, want %q", tt.format, tt.keys, err, tt.want)
+		}
+		if b.Len() > 0 {
+			t.Errorf("%s %v: partial output %q", tt.format, tt.keys, b.String())
+		}
+	}
+
+	// The other formats can hold any name.
+	for _, format := range []string{"dotenv", "json", "yaml", "github"} {
+		if err := Export(&strings.Builder{}, format, []string{"MY-KEY", "a.b"}, values); err != nil {
+			t.Errorf("%s: %v", format, err)
+		}
+	}
+}
@@ -1,55 +1,355 @@
 package env
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/crypt"
+	"github.com/akpatel363/menv/internal/provider"
 )
 
-// LoadEnv loads environment variables from the given project and env config.
-// It reads .env-style files relative to the project path, then applies overrides.
-// Returns a merged map of key=value pairs.
-func LoadEnv(project config.Project, envCfg config.Env) (map[string]string, error) {
-	result := make(map[string]string)
+// LoadEnv loads environment variables for the env called envName.
+// It walks the env's layers (see config.Config.EnvChain): global defaults,
+// project base, inherited envs and finally the env itself. For each layer it
+// reads its env files (dotenv, JSON, YAML or TOML) relative to the project
+// path, then its secret store sources (Vault, SSM), then applies its
+// overrides, so later layers take precedence.
+// Schema defaults are applied last, for variables that are still unset.
+// Values from dotenv files and overrides are expanded (see expand) against
+// keys defined earlier in the same file, keys from earlier files, the
+// layer's overrides and the OS variables that survive opts (see BaseEnv), so
+// that in pure mode only inherited variables expand. Overrides written as references
+// such as ref+exec://... are resolved by their provider (see package
+// provider) instead, as are unquoted and double-quoted values of files with
+// Refs set; a value only becomes a reference as written, never through
+// expansion.
+// Returns the merged variables, each annotated with its source.
+func LoadEnv(cfg *config.Config, project config.Project, envName string, opts BuildOptions) (Vars, error) {
+	chain, err := cfg.EnvChain(project, envName)
+	if err != nil {
+		return nil, err
+	}
+	osEnv := BaseEnv(opts)
+
+	strict := config.MergeLayers(chain).Strict
 
-	for _, f := range envCfg.Files {
-		filePath := f
-		if !filepath.IsAbs(filePath) {
-			filePath = filepath.Join(project.Path, f)
+	result := make(Vars)
+	for _, l := range chain {
+		// Values inherited from another layer are labelled with its name.
+		layer := ""
+		if l.Name != envName {
+			layer = l.Name
 		}
 
-		vars, err := parseEnvFile(filePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load env file %s: %w", filePath, err)
+		for _, f := range l.Env.Files {
+			entries, issues, err := readFile(project, f)
+			if err != nil {
+				return nil, err
+			}
+			if strict && len(issues) > 0 {
+				return nil, strictError(issues)
+			}
+			for _, e := range entries {
+				value := e.value
+				switch {
+				case e.literal():
+				case f.Refs && provider.IsRef(value):
+					value, err = provider.Default.Resolve(value, project.Path)
+				default:
+					value, err = expandFileValue(unescapeRef(value), result, osEnv, l.Env.Overrides, project.Path)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %s: %w", f.Path, e.line, e.key, err)
+				}
+				result.set(e.key, value, Source{Kind: SourceFile, File: f.Path, Line: e.line, Layer: layer}, osEnv)
+			}
 		}
-		for k, v := range vars {
-			result[k] = v
+
+		if src := l.Env.Vault; src != nil {
+			values, err := vaultValues(*src)
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range sortedKeys(values) {
+				result.set(k, values[k], Source{Kind: SourceVault, File: vaultSecret(*src).String(), Layer: layer}, osEnv)
+			}
+		}
+		if src := l.Env.SSM; src != nil {
+			values, err := ssmValues(*src, lookupIn(result, osEnv))
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range sortedKeys(values) {
+				result.set(k, values[k], Source{Kind: SourceSSM, File: src.Path, Layer: layer}, osEnv)
+			}
+		}
+
+		// Overrides take precedence over file-loaded and secret store values.
+		if err := ApplyOverrides(result, project, l.Env.Overrides, layer, opts); err != nil {
+			return nil, err
 		}
 	}
 
-	// Overrides take precedence over file-loaded values.
-	for k, v := range envCfg.Overrides {
-		result[k] = v
+	// Schema defaults fill in variables no layer has set.
+	for k, field := range project.EnvSchema(chain) {
+		if _, ok := result[k]; !ok && field.Default != nil {
+			result.set(k, *field.Default, Source{Kind: SourceDefault}, osEnv)
+		}
 	}
 
 	return result, nil
 }
 
-// BuildEnv merges the current OS environment with the loaded env vars.
-// Loaded vars override existing OS vars with the same key.
-func BuildEnv(loaded map[string]string) []string {
-	existing := make(map[string]string)
-	for _, e := range os.Environ() {
-		parts := strings.SplitN(e, "=", 2)
-		if len(parts) == 2 {
-			existing[parts[0]] = parts[1]
+// ApplyOverrides sets overrides on vars the way LoadEnv applies an env's
+// overrides: references resolved, other values expanded against vars, each
+// other and the OS variables that survive opts. The values are labelled as
+// coming from layer, if not empty.
+func ApplyOverrides(vars Vars, project config.Project, overrides map[string]string, layer string, opts BuildOptions) error {
+	osEnv := BaseEnv(opts)
+	expanded, err := expandOverrides(overrides, vars, osEnv, project.Path)
+	if err != nil {
+		return err
+	}
+	for k, v := range expanded {
+		vars.set(k, v, Source{Kind: SourceOverride, Layer: layer}, osEnv)
+	}
+	return nil
+}
+
+// Schema returns the schema that applies to envName, see config.Project.EnvSchema.
+func Schema(cfg *config.Config, project config.Project, envName string) (map[string]config.SchemaField, error) {
+	chain, err := cfg.EnvChain(project, envName)
+	if err != nil {
+		return nil, err
+	}
+	return project.EnvSchema(chain), nil
+}
+
+// Lint parses every env file of envName, including those from other layers, and
+// returns the problems found, in load order.
+func Lint(cfg *config.Config, project config.Project, envName string) ([]Issue, error) {
+	chain, err := cfg.EnvChain(project, envName)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Issue
+	for _, l := range chain {
+		for _, f := range l.Env.Files {
+			_, issues, err := readFile(project, f)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, issues...)
+		}
+	}
+	return all, nil
+}
+
+// readFile loads the entries of a single Env.Files entry, relative to the
+// project path, decrypting it if needed and parsing it according to its format.
+func readFile(project config.Project, ref config.FileRef) ([]entry, []Issue, error) {
+	filePath := resolvePath(project, ref)
+
+	format, err := fileFormat(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load env file %s: %w", filePath, err)
+	}
+
+	if ref.Encrypted || strings.HasSuffix(ref.Path, ".enc") {
+		if data, err = crypt.Decrypt(data, crypt.Default); err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt env file %s: %w", filePath, err)
 		}
 	}
 
+	if format == formatDotenv && isSOPSDotenv(data) {
+		if data, err = decryptSOPSDotenv(data); err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt env file %s: %w", filePath, err)
+		}
+	}
+
+	if format != formatDotenv {
+		entries, err := parseStructured(data, format, ref)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s file %s: %w", format, filePath, err)
+		}
+		return entries, nil, nil
+	}
+
+	entries, issues := parseDotenv(string(data))
+	for i := range issues {
+		issues[i].File = ref.Path
+	}
+	return entries, issues, nil
+}
+
+// resolvePath returns the path of ref, relative to the project path unless
+// it is absolute.
+func resolvePath(project config.Project, ref config.FileRef) string {
+	if filepath.IsAbs(ref.Path) {
+		return ref.Path
+	}
+	return filepath.Join(project.Path, ref.Path)
+}
+
+// strictError reports env file issues as a single error.
+func strictError(issues []Issue) error {
+	lines := make([]string, len(issues))
+	for i, is := range issues {
+		lines[i] = "  " + is.String()
+	}
+	return fmt.Errorf("strict mode: %d problem(s) in env files:\n%s", len(issues), strings.Join(lines, "\n"))
+}
+
+// lookupIn returns a lookupFunc that consults vars first, then osEnv.
+func lookupIn(vars Vars, osEnv map[string]string) lookupFunc {
+	return func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v.Value, true
+		}
+		v, ok := osEnv[name]
+		return v, ok
+	}
+}
+
+// expandFileValue expands a value from one of a layer's files. The layer's
+// overrides take effect once its files are loaded, so a reference to an
+// overridden key resolves to the override, expanded against vars as loaded
+// so far; other keys are looked up in vars, then osEnv.
+func expandFileValue(value string, vars Vars, osEnv map[string]string, overrides map[string]string, dir string) (string, error) {
+	var lookupErr error
+	v, err := expand(value, func(name string) (string, bool) {
+		if _, ok := overrides[name]; ok {
+			v, err := newOverrideExpander(overrides, vars, osEnv, dir).value(name)
+			if err != nil && lookupErr == nil {
+				lookupErr = err
+			}
+			return v, true
+		}
+		return lookupIn(vars, osEnv)(name)
+	})
+	if lookupErr != nil {
+		return "", lookupErr
+	}
+	return v, err
+}
+
+// unescapeRef removes the backslash that keeps a value such as \ref+x from
+// being taken as a reference: one or more backslashes followed by ref+ stand
+// for one backslash less.
+func unescapeRef(value string) string {
+	if rest := strings.TrimLeft(value, `\`); len(rest) < len(value) && provider.IsRef(rest) {
+		return value[1:]
+	}
+	return value
+}
+
+// expandOverrides resolves override values that are references, relative
+// to dir, and expands the others. Overrides may reference each other in any
+// order; a reference from an override to its own key resolves to the value
+// it replaces (from files or osEnv).
+func expandOverrides(overrides map[string]string, base Vars, osEnv map[string]string, dir string) (map[string]string, error) {
+	x := newOverrideExpander(overrides, base, osEnv, dir)
+	for _, k := range sortedKeys(overrides) {
+		if _, err := x.value(k); err != nil {
+			return nil, err
+		}
+	}
+	return x.resolved, nil
+}
+
+// overrideExpander expands override values on demand, detecting reference
+// cycles between them.
+type overrideExpander struct {
+	overrides map[string]string
+	base      Vars
+	osEnv     map[string]string
+	dir       string
+	resolved  map[string]string
+	stack     []string
+}
+
+func newOverrideExpander(overrides map[string]string, base Vars, osEnv map[string]string, dir string) *overrideExpander {
+	return &overrideExpander{overrides: overrides, base: base, osEnv: osEnv, dir: dir, resolved: make(map[string]string, len(overrides))}
+}
+
+// value returns the resolved or expanded value of the override for key.
+func (x *overrideExpander) value(key string) (string, error) {
+	if v, ok := x.resolved[key]; ok {
+		return v, nil
+	}
+	if raw := x.overrides[key]; provider.IsRef(raw) {
+		v, err := provider.Default.Resolve(raw, x.dir)
+		if err != nil {
+			return "", fmt.Errorf("override %s: %w", key, err)
+		}
+		x.resolved[key] = v
+		return v, nil
+	}
+	for i, k := range x.stack {
+		if k == key {
+			chain := append(append([]string{}, x.stack[i:]...), key)
+			return "", fmt.Errorf("variable reference cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+
+	x.stack = append(x.stack, key)
+	defer func() { x.stack = x.stack[:len(x.stack)-1] }()
+
+	var lookupErr error
+	v, err := expand(unescapeRef(x.overrides[key]), func(name string) (string, bool) {
+		if _, ok := x.overrides[name]; ok && name != key {
+			v, err := x.value(name)
+			if err != nil && lookupErr == nil {
+				lookupErr = err
+			}
+			return v, true
+		}
+		return lookupIn(x.base, x.osEnv)(name)
+	})
+	if lookupErr != nil {
+		return "", lookupErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("override %s: %w", key, err)
+	}
+	x.resolved[key] = v
+	return v, nil
+}
+
+// DefaultInherit lists the OS variables passed through in pure mode when an
+// env does not configure its own inherit list.
+var DefaultInherit = []string{"PATH", "HOME", "USER", "SHELL", "TERM", "LANG", "LC_*", "TMPDIR"}
+
+// windowsInherit is added to DefaultInherit on Windows, where programs fail
+// to start without these.
+var windowsInherit = []string{"SYSTEMROOT", "COMSPEC", "PATHEXT", "TEMP", "TMP", "WINDIR"}
+
+// BuildOptions controls which OS variables BuildEnv starts from.
+type BuildOptions struct {
+	// Pure starts from an empty environment; only OS variables matching
+	// Inherit (or DefaultInherit if Inherit is empty) are passed through.
+	Pure    bool
+	Inherit []string
+	// Unset removes OS variables matching these patterns.
+	Unset []string
+}
+
+// BuildEnv merges the OS environment, filtered according to opts, with the
+// loaded env vars. Loaded vars override existing OS vars with the same key.
+func BuildEnv(loaded map[string]string, opts BuildOptions) []string {
+	existing := BaseEnv(opts)
+
 	// Apply loaded env vars (overrides OS vars).
 	for k, v := range loaded {
 		existing[k] = v
@@ -62,56 +362,46 @@ func BuildEnv(loaded map[string]string) []string {
 	return env
 }
 
-// parseEnvFile reads a .env file and returns the key-value pairs.
-// Supports:
-//   - KEY=VALUE
-//   - KEY="VALUE" (double-quoted, strips quotes)
-//   - KEY='VALUE' (single-quoted, strips quotes)
-//   - Comments (#)
-//   - Empty lines
-//   - export KEY=VALUE (strips the `export` prefix)
-func parseEnvFile(path string) (map[string]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+// BaseEnv returns the OS variables that survive opts.
+func BaseEnv(opts BuildOptions) map[string]string {
+	inherit := opts.Inherit
+	if opts.Pure && len(inherit) == 0 {
+		inherit = DefaultInherit
+		if runtime.GOOS == "windows" {
+			inherit = append(append([]string{}, inherit...), windowsInherit...)
+		}
 	}
-	defer file.Close()
 
-	result := make(map[string]string)
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// Skip empty lines and comments.
-		if line == "" || strings.HasPrefix(line, "#") {
+	existing := make(map[string]string)
+	for _, e := range os.Environ() {
+		parts := strings.SplitN(e, "=", 2)
+		if len(parts) != 2 {
 			continue
 		}
-
-		// Strip optional 'export ' prefix.
-		if strings.HasPrefix(line, "export ") {
-			line = strings.TrimPrefix(line, "export ")
-			line = strings.TrimSpace(line)
+		if opts.Pure && !matchAny(inherit, parts[0]) {
+			continue
 		}
-
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
+		if matchAny(opts.Unset, parts[0]) {
 			continue
 		}
+		existing[parts[0]] = parts[1]
+	}
+	return existing
+}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-
-		// Remove surrounding quotes.
-		if len(value) >= 2 {
-			if (value[0] == '"' && value[len(value)-1] == '"') ||
-				(value[0] == '\'' && value[len(value)-1] == '\'') {
-				value = value[1 : len(value)-1]
-			}
+// matchAny reports whether name matches one of the glob patterns.
+// Matching is case-insensitive on Windows, like its environment.
+func matchAny(patterns []string, name string) bool {
+	if runtime.GOOS == "windows" {
+		name = strings.ToUpper(name)
+	}
+	for _, p := range patterns {
+		if runtime.GOOS == "windows" {
+			p = strings.ToUpper(p)
+		}
+		if ok, _ := path.Match(p, name); ok {
+			return true
 		}
-
-		result[key] = value
 	}
-
-	return result, scanner.Err()
+	return false
 }
@@ -0,0 +1,122 @@
+package env
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akpatel363/menv/internal/config"
+)
+
+// Violation is a schema rule broken by a resolved env.
+type Violation struct {
+	Key     string
+	Message string
+}
+
+// String formats the violation as "KEY: message".
+func (v Violation) String() string {
+	return v.Key + ": " + v.Message
+}
+
+// ValidationError is returned by CheckSchema when an env breaks its schema.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		lines[i] = "  " + v.String()
+	}
+	return fmt.Sprintf("schema validation failed with %d violation(s):\n%s", len(e.Violations), strings.Join(lines, "\n"))
+}
+
+// Validate checks vars against schema and returns the violations, sorted by
+// key. Required variables must be set to a non-empty value; variables that
+// are set must match their declared type. Messages quote the offending
+// value, except for keys that are secret (see IsSecret with secrets).
+func Validate(vars Vars, schema map[string]config.SchemaField, secrets []string) []Violation {
+	keys := make([]string, 0, len(schema))
+	for k := range schema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var violations []Violation
+	for _, k := range keys {
+		field := schema[k]
+		v, ok := vars[k]
+		if !ok || v.Value == "" {
+			if field.Required {
+				violations = append(violations, Violation{Key: k, Message: "required but not set"})
+			}
+			continue
+		}
+		got := fmt.Sprintf(", got %q", v.Value)
+		if IsSecret(k, secrets) {
+			got = ""
+		}
+		if err := checkType(v.Value, got, field); err != nil {
+			violations = append(violations, Violation{Key: k, Message: err.Error()})
+		}
+	}
+	return violations
+}
+
+// CheckSchema is like Validate but returns a *ValidationError if there are
+// any violations.
+func CheckSchema(vars Vars, schema map[string]config.SchemaField, secrets []string) error {
+	if violations := Validate(vars, schema, secrets); len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// checkType reports whether value is valid for the field's type. got is
+// appended to messages about the value, such as `, got "abc"`.
+func checkType(value, got string, field config.SchemaField) error {
+	switch strings.ToLower(field.Type) {
+	case "", "string":
+		return nil
+	case "int", "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("expected int%s", got)
+		}
+	case "bool", "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("expected bool%s", got)
+		}
+	case "url":
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			return fmt.Errorf("expected absolute URL%s", got)
+		}
+	case "duration":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("expected duration (e.g. 30s, 5m)%s", got)
+		}
+	case "enum":
+		for _, allowed := range field.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("expected one of [%s]%s", strings.Join(field.Values, ", "), got)
+	case "regex":
+		re, err := regexp.Compile("^(?:" + field.Pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q in schema: %v", field.Pattern, err)
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("expected to match /%s/%s", field.Pattern, got)
+		}
+	default:
+		return fmt.Errorf("unknown type %q in schema", field.Type)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package env
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akpatel363/menv/internal/config"
+)
+
+func TestValidateMasksSecrets(t *testing.T) {
+	vars := Vars{}
+	vars.set("PORT", "abc", Source{Kind: SourceOverride}, nil)
+	vars.set("DB_PASSWORD", "hunter2", Source{Kind: SourceOverride}, nil)
+	vars.set("MY_CRED", "topsecret", Source{Kind: SourceOverride}, nil)
+	schema := map[string]config.SchemaField{
+		"PORT":        {Type: "int"},
+		"DB_PASSWORD": {Type: "int"},
+		"MY_CRED":     {Type: "enum", Values: []string{"a", "b"}},
+		"API_URL":     {Required: true},
+	}
+
+	got := Validate(vars, schema, []string{"my_*"})
+	want := []Violation{
+		{Key: "API_URL", Message: "required but not set"},
+		{Key: "DB_PASSWORD", Message: "expected int"},
+		{Key: "MY_CRED", Message: "expected one of [a, b]"},
+		{Key: "PORT", Message: `expected int, got "abc"`},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("violation %d = %v, want %v", i, got[i], want[i])
+		}
+		for _, secret := range []string{"hunter2", "topsecret"} {
+			if strings.Contains(got[i].Message, secret) {
+				t.Errorf("violation %v reveals a secret value", got[i])
+			}
+		}
+	}
+}
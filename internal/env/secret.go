@@ -0,0 +1,35 @@
+package env
+
+import (
+	"path"
+	"strings"
+)
+
+// DefaultSecretPatterns are key patterns that are always treated as secret.
+var DefaultSecretPatterns = []string{"*_TOKEN", "*_KEY", "*_SECRET", "*PASSWORD*"}
+
+// mask replaces secret values in display output.
+const mask = "********"
+
+// IsSecret reports whether key matches DefaultSecretPatterns or one of the
+// given glob patterns. Matching is case-insensitive.
+func IsSecret(key string, patterns []string) bool {
+	key = strings.ToUpper(key)
+	for _, list := range [][]string{DefaultSecretPatterns, patterns} {
+		for _, p := range list {
+			if ok, _ := path.Match(strings.ToUpper(p), key); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Mask returns value, or a fixed placeholder if key is secret.
+// Empty values are left as-is, since they reveal nothing.
+func Mask(key, value string, patterns []string) string {
+	if value == "" || !IsSecret(key, patterns) {
+		return value
+	}
+	return mask
+}
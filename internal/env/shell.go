@@ -0,0 +1,189 @@
+package env
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Shells lists the shells accepted by ShellScript.
+var Shells = []string{"bash", "zsh", "fish", "powershell", "nu", "tcsh"}
+
+// RestoreVar holds the values a ShellScript replaced, so that
+// ShellUnloadScript can put them back. Loading several envs in a row stacks,
+// since RestoreVar itself is saved along with the other values.
+const RestoreVar = "MENV_RESTORE"
+
+// shellSyntax knows how to set and unset a variable in one shell.
+type shellSyntax struct {
+	set   func(k, v string) string
+	unset func(k string) string
+}
+
+var posixSyntax = shellSyntax{
+	set:   func(k, v string) string { return "export " + k + "=" + shellQuote(v) },
+	unset: func(k string) string { return "unset " + k },
+}
+
+var shellSyntaxes = map[string]shellSyntax{
+	"bash": posixSyntax,
+	"fish": {
+		set: func(k, v string) string {
+			return "set -gx " + k + " '" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+		},
+		unset: func(k string) string { return "set -e " + k },
+	},
+	"powershell": {
+		set: func(k, v string) string {
+			// PowerShell also treats typographic single quotes as quotes.
+			r := strings.NewReplacer("'", "''", "‘", "‘‘", "’", "’’", "‚", "‚‚", "‛", "‛‛")
+			return "${env:" + k + "} = '" + r.Replace(v) + "'"
+		},
+		unset: func(k string) string { return "Remove-Item -ErrorAction SilentlyContinue Env:" + k },
+	},
+	"nu": {
+		set:   func(k, v string) string { return "load-env {" + nuString(k) + ": " + nuRawString(v) + "}" },
+		unset: func(k string) string { return "hide-env -i " + nuString(k) },
+	},
+	"tcsh": {
+		set: func(k, v string) string {
+			r := strings.NewReplacer("'", `'\''`, "!", `\!`, "\n", "\\\n")
+			return "setenv " + k + " '" + r.Replace(v) + "'"
+		},
+		unset: func(k string) string { return "unsetenv " + k },
+	},
+}
+
+// shellAliases maps other names, as found in $SHELL, to a known syntax.
+var shellAliases = map[string]string{
+	"sh":      "bash",
+	"zsh":     "bash",
+	"dash":    "bash",
+	"ksh":     "bash",
+	"ash":     "bash",
+	"pwsh":    "powershell",
+	"nushell": "nu",
+	"csh":     "tcsh",
+}
+
+// ResolveShell returns the syntax name for shell. An empty name or "auto"
+// detects the shell from $SHELL, falling back to PowerShell on Windows.
+func ResolveShell(shell string) (string, error) {
+	if shell == "" || shell == "auto" {
+		if s := os.Getenv("SHELL"); s != "" {
+			shell = filepath.Base(s)
+		} else if runtime.GOOS == "windows" {
+			shell = "powershell"
+		} else {
+			return "", fmt.Errorf("cannot detect the shell from $SHELL; use --shell <name> (one of: %s)", strings.Join(Shells, ", "))
+		}
+	}
+	name := strings.TrimSuffix(strings.ToLower(shell), ".exe")
+	if alias, ok := shellAliases[name]; ok {
+		name = alias
+	}
+	if _, ok := shellSyntaxes[name]; !ok {
+		return "", fmt.Errorf("unsupported shell %q (expected one of: %s)", shell, strings.Join(Shells, ", "))
+	}
+	return name, nil
+}
+
+// ShellScript returns statements that set the variables named by keys in
+// shell (see ResolveShell), for use with eval or its equivalent. The values
+// they replace are saved in RestoreVar for ShellUnloadScript.
+func ShellScript(shell string, keys []string, values map[string]string) (string, error) {
+	name, err := ResolveShell(shell)
+	if err != nil {
+		return "", err
+	}
+	syntax := shellSyntaxes[name]
+	for _, k := range keys {
+		if !IsIdentifier(k) {
+			return "", fmt.Errorf("key %q is not a valid shell identifier", k)
+		}
+	}
+
+	previous := make(map[string]*string, len(keys)+1)
+	for _, k := range append(append([]string{}, keys...), RestoreVar) {
+		if v, ok := os.LookupEnv(k); ok {
+			previous[k] = &v
+		} else {
+			previous[k] = nil
+		}
+	}
+	snapshot, err := json.Marshal(previous)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(syntax.set(k, values[k]) + "\n")
+	}
+	b.WriteString(syntax.set(RestoreVar, base64.StdEncoding.EncodeToString(snapshot)) + "\n")
+	return b.String(), nil
+}
+
+// ShellUnloadScript returns statements for shell that undo the most recent
+// ShellScript, restoring or unsetting each variable it set, using the
+// snapshot in RestoreVar.
+func ShellUnloadScript(shell string) (string, error) {
+	name, err := ResolveShell(shell)
+	if err != nil {
+		return "", err
+	}
+	syntax := shellSyntaxes[name]
+
+	encoded := os.Getenv(RestoreVar)
+	if encoded == "" {
+		return "", fmt.Errorf("nothing to unload: $%s is not set (load an env with --shell first)", RestoreVar)
+	}
+	var previous map[string]*string
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(data, &previous)
+	}
+	if err != nil {
+		return "", fmt.Errorf("malformed $%s: %w", RestoreVar, err)
+	}
+
+	keys := make([]string, 0, len(previous))
+	for k := range previous {
+		if !IsIdentifier(k) {
+			return "", fmt.Errorf("malformed $%s: key %q is not a valid shell identifier", RestoreVar, k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		if v := previous[k]; v != nil {
+			b.WriteString(syntax.set(k, *v) + "\n")
+		} else {
+			b.WriteString(syntax.unset(k) + "\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// nuString quotes s as a nushell double-quoted string.
+func nuString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// nuRawString quotes s as a nushell raw string (r#'...'#), which takes its
+// contents literally, using enough #s that the closing delimiter does not
+// occur in s.
+func nuRawString(s string) string {
+	hashes := "#"
+	for strings.Contains(s, "'"+hashes) {
+		hashes += "#"
+	}
+	return "r" + hashes + "'" + s + "'" + hashes
+}
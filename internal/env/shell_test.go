@@ -0,0 +1,178 @@
+package env
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestShellQuoting(t *testing.T) {
+	tests := []struct {
+		shell string
+		value string
+		want  string
+	}{
+		{"bash", "plain", "export K=plain"},
+		{"bash", `it's $HOME \n`, `export K='it'\''s $HOME \n'`},
+		{"bash", "a\nb", "export K='a\nb'"},
+		{"fish", "plain", "set -gx K 'plain'"},
+		{"fish", `it's $HOME \n`, `set -gx K 'it\'s $HOME \\n'`},
+		{"fish", "a\nb", "set -gx K 'a\nb'"},
+		{"powershell", "plain", "${env:K} = 'plain'"},
+		{"powershell", `it's $HOME`, "${env:K} = 'it''s $HOME'"},
+		{"powershell", "‘typographic’ ‚quotes‛", "${env:K} = '‘‘typographic’’ ‚‚quotes‛‛'"},
+		{"nu", "plain", `load-env {"K": r#'plain'#}`},
+		{"nu", `it's "$HOME" \n`, `load-env {"K": r#'it's "$HOME" \n'#}`},
+		{"nu", "ends with '# and '##", `load-env {"K": r###'ends with '# and '##'###}`},
+		{"tcsh", "plain", "setenv K 'plain'"},
+		{"tcsh", "it's $HOME!", `setenv K 'it'\''s $HOME\!'`},
+		{"tcsh", "a\nb", "setenv K 'a\\\nb'"},
+	}
+	for _, tt := range tests {
+		got, err := ShellScript(tt.shell, []string{"K"}, map[string]string{"K": tt.value})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.shell, err)
+		}
+		// The last line saves the snapshot for --unload.
+		got = got[:strings.LastIndex(strings.TrimSuffix(got, "\n"), "\n")]
+		if got != tt.want {
+			t.Errorf("%s %q:\n got %s\nwant %s", tt.shell, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestShellUnset(t *testing.T) {
+	want := map[string]string{
+		"bash":       "unset K",
+		"fish":       "set -e K",
+		"powershell": "Remove-Item -ErrorAction SilentlyContinue Env:K",
+		"nu":         `hide-env -i "K"`,
+		"tcsh":       "unsetenv K",
+	}
+	for shell, w := range want {
+		if got := shellSyntaxes[shell].unset("K"); got != w {
+			t.Errorf("%s: got %s, want %s", shell, got, w)
+		}
+	}
+}
+
+func TestResolveShell(t *testing.T) {
+	tests := []struct {
+		shell, env, want, wantErr string
+	}{
+		{shell: "zsh", want: "bash"},
+		{shell: "pwsh.exe", want: "powershell"},
+		{shell: "NuShell", want: "nu"},
+		{shell: "csh", want: "tcsh"},
+		{shell: "auto", env: "/usr/local/bin/fish", want: "fish"},
+		{shell: "", env: "/bin/zsh", want: "bash"},
+		{shell: "cmd", wantErr: `unsupported shell "cmd"`},
+		{shell: "auto", env: "/bin/elvish", wantErr: `unsupported shell "elvish"`},
+	}
+	for _, tt := range tests {
+		t.Setenv("SHELL", tt.env)
+		got, err := ResolveShell(tt.shell)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: error = %v, want %q", tt.shell, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q (SHELL=%s) = %q, %v; want %q", tt.shell, tt.env, got, err, tt.want)
+		}
+	}
+}
+
+func TestShellScriptRejectsInvalidKeys(t *testing.T) {
+	for _, k := range []string{"MY-KEY", "1ST", "A B", "X;rm -rf ~", ""} {
+		if _, err := ShellScript("bash", []string{k}, map[string]string{k: "v"}); err == nil || !strings.Contains(err.Error(), "not a valid shell identifier") {
+			t.Errorf("%q: error = %v", k, err)
+		}
+	}
+
+	snapshot := base64.StdEncoding.EncodeToString([]byte(`{"A;echo pwned":null}`))
+	t.Setenv(RestoreVar, snapshot)
+	if _, err := ShellUnloadScript("bash"); err == nil || !strings.Contains(err.Error(), "not a valid shell identifier") {
+		t.Errorf("unload: error = %v", err)
+	}
+}
+
+func TestShellRestoreRoundTrip(t *testing.T) {
+	t.Setenv("MENV_TEST_SET", "old 'value'\nline 2")
+	t.Setenv("MENV_TEST_UNSET", "")
+	os.Unsetenv("MENV_TEST_UNSET")
+	t.Setenv(RestoreVar, "")
+	os.Unsetenv(RestoreVar)
+
+	// apply sets the variables of a bash script in the environment, as
+	// eval would.
+	apply := func(script string) {
+		t.Helper()
+		lines := strings.Split(strings.TrimSuffix(script, "\n"), "\n")
+		for _, k := range []string{"MENV_TEST_SET", "MENV_TEST_UNSET"} {
+			if strings.Contains(script, "export "+k+"=new\n") {
+				os.Setenv(k, "new")
+			}
+		}
+		last := lines[len(lines)-1]
+		encoded, ok := strings.CutPrefix(last, "export "+RestoreVar+"=")
+		if !ok {
+			t.Fatalf("last line does not set %s: %s", RestoreVar, last)
+		}
+		os.Setenv(RestoreVar, encoded)
+	}
+
+	values := map[string]string{"MENV_TEST_SET": "new", "MENV_TEST_UNSET": "new"}
+	first, err := ShellScript("bash", []string{"MENV_TEST_SET", "MENV_TEST_UNSET"}, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(first)
+	firstSnapshot := os.Getenv(RestoreVar)
+
+	// A second load stacks on the first: unloading it restores the first
+	// snapshot rather than unsetting MENV_RESTORE.
+	second, err := ShellScript("fish", []string{"MENV_TEST_SET"}, map[string]string{"MENV_TEST_SET": "newer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(second, "set -gx MENV_TEST_SET 'newer'\nset -gx "+RestoreVar+" '") {
+		t.Fatalf("unexpected fish script:\n%s", second)
+	}
+	snapshot := strings.TrimSuffix(strings.TrimPrefix(strings.Split(second, "\n")[1], "set -gx "+RestoreVar+" '"), "'")
+	os.Setenv(RestoreVar, snapshot)
+	got, err := ShellUnloadScript("fish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "set -gx " + RestoreVar + " '" + firstSnapshot + "'\n" +
+		"set -gx MENV_TEST_SET 'new'\n"
+	if got != want {
+		t.Errorf("unload of the second load:\n got %s\nwant %s", got, want)
+	}
+
+	os.Setenv(RestoreVar, firstSnapshot)
+	got, err = ShellUnloadScript("bash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "unset " + RestoreVar + "\n" +
+		"export MENV_TEST_SET='old '\\''value'\\''\nline 2'\n" +
+		"unset MENV_TEST_UNSET\n"
+	if got != want {
+		t.Errorf("unload of the first load:\n got %s\nwant %s", got, want)
+	}
+
+	for _, bad := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("[1]"))} {
+		os.Setenv(RestoreVar, bad)
+		if _, err := ShellUnloadScript("bash"); err == nil || !strings.Contains(err.Error(), "malformed $"+RestoreVar) {
+			t.Errorf("%q: error = %v", bad, err)
+		}
+	}
+	os.Unsetenv(RestoreVar)
+	if _, err := ShellUnloadScript("bash"); err == nil || !strings.Contains(err.Error(), "nothing to unload") {
+		t.Errorf("unset: error = %v", err)
+	}
+}
@@ -0,0 +1,422 @@
+package env
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// This file decrypts files encrypted with SOPS (https://getsops.io) using
+// age recipients, in-process and without the sops binary. Dotenv, YAML and
+// JSON files are supported. The data key is recovered with the local age
+// identities, every value is decrypted with it, and the file's MAC is
+// verified before any value is used.
+
+// sopsMetadataKey is the top-level key (or, in dotenv files, key prefix)
+// holding SOPS metadata.
+const sopsMetadataKey = "sops"
+
+// sopsMACOnlyEncryptedInit seeds the MAC of files written with
+// mac_only_encrypted, matching SOPS.
+var sopsMACOnlyEncryptedInit = []byte{0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0xb, 0xb, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69}
+
+var sopsValueRe = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+// sopsDotenvAgeRe matches flattened age key entries in dotenv metadata,
+// both top-level and inside key groups.
+var sopsDotenvAgeRe = regexp.MustCompile(`^(?:key_groups__list_\d+__map_)?age__list_\d+__map_enc$`)
+
+// sopsMetadata is the subset of SOPS metadata menv needs.
+type sopsMetadata struct {
+	Age       []sopsAgeKey `yaml:"age"`
+	KeyGroups []struct {
+		Age []sopsAgeKey `yaml:"age"`
+	} `yaml:"key_groups"`
+	LastModified     string `yaml:"lastmodified"`
+	MAC              string `yaml:"mac"`
+	MACOnlyEncrypted bool   `yaml:"mac_only_encrypted"`
+}
+
+type sopsAgeKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+// sopsDecryptor decrypts the values of one file and accumulates its MAC.
+type sopsDecryptor struct {
+	md   sopsMetadata
+	key  []byte
+	hash hash.Hash
+}
+
+// newSOPSDecryptor recovers the file's data key from its age stanzas.
+func newSOPSDecryptor(md sopsMetadata) (*sopsDecryptor, error) {
+	stanzas := md.Age
+	for _, g := range md.KeyGroups {
+		stanzas = append(stanzas, g.Age...)
+	}
+	if len(stanzas) == 0 {
+		return nil, fmt.Errorf("sops: file has no age recipients (only age-encrypted files are supported)")
+	}
+
+	ids, err := sopsAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	var key []byte
+	for _, s := range stanzas {
+		r, err := age.Decrypt(armor.NewReader(strings.NewReader(s.Enc)), ids...)
+		if err != nil {
+			continue
+		}
+		if key, err = io.ReadAll(r); err == nil {
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("sops: none of the local age identities can decrypt this file")
+	}
+
+	d := &sopsDecryptor{md: md, key: key, hash: sha512.New()}
+	if md.MACOnlyEncrypted {
+		d.hash.Write(sopsMACOnlyEncryptedInit)
+	}
+	return d, nil
+}
+
+// leaf decrypts value if it is encrypted, using path as additional data,
+// and adds it to the MAC. It returns the plaintext and SOPS type
+// ("str" for values that were not encrypted).
+func (d *sopsDecryptor) leaf(value string, path []string) (string, string, error) {
+	if !sopsValueRe.MatchString(value) {
+		if !d.md.MACOnlyEncrypted {
+			d.hash.Write([]byte(value))
+		}
+		return value, "str", nil
+	}
+	plaintext, typ, err := sopsDecryptValue(value, d.key, sopsAAD(path))
+	if err != nil {
+		return "", "", fmt.Errorf("sops: %s: %w", strings.Join(path, "."), err)
+	}
+	d.hash.Write([]byte(plaintext))
+	return plaintext, typ, nil
+}
+
+// comment decrypts a comment if it is encrypted. SOPS encrypts a comment
+// with the path of the mapping it appears in as additional data and leaves
+// it out of the MAC.
+func (d *sopsDecryptor) comment(value string, path []string) (string, error) {
+	if !sopsValueRe.MatchString(value) {
+		return value, nil
+	}
+	plaintext, _, err := sopsDecryptValue(value, d.key, sopsAAD(path))
+	if err != nil {
+		return "", fmt.Errorf("sops: comment: %w", err)
+	}
+	return plaintext, nil
+}
+
+// sopsAAD returns the additional data SOPS authenticates a value at path
+// with: the keys leading to it, each followed by a colon.
+func sopsAAD(path []string) string {
+	return strings.Join(path, ":") + ":"
+}
+
+// verify checks the accumulated MAC against the one stored in the file.
+func (d *sopsDecryptor) verify() error {
+	modified, err := time.Parse(time.RFC3339, d.md.LastModified)
+	if err != nil {
+		return fmt.Errorf("sops: invalid lastmodified %q", d.md.LastModified)
+	}
+	expected, _, err := sopsDecryptValue(d.md.MAC, d.key, modified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("sops: failed to decrypt MAC: %w", err)
+	}
+	if fmt.Sprintf("%X", d.hash.Sum(nil)) != expected {
+		return fmt.Errorf("sops: MAC mismatch, the file has been modified")
+	}
+	return nil
+}
+
+// sopsDecryptValue opens a single ENC[AES256_GCM,...] value.
+func sopsDecryptValue(value string, key []byte, aad string) (string, string, error) {
+	m := sopsValueRe.FindStringSubmatch(value)
+	if m == nil {
+		return "", "", fmt.Errorf("malformed encrypted value")
+	}
+	var parts [3][]byte
+	for i := range parts {
+		b, err := base64.StdEncoding.DecodeString(m[i+1])
+		if err != nil {
+			return "", "", fmt.Errorf("malformed encrypted value: %w", err)
+		}
+		parts[i] = b
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return "", "", err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(aad))
+	if err != nil {
+		return "", "", fmt.Errorf("decryption failed")
+	}
+	return string(plaintext), m[4], nil
+}
+
+// sopsAgeIdentities loads age identities the way SOPS does: from
+// $SOPS_AGE_KEY, and from $SOPS_AGE_KEY_FILE or the default keys file
+// (sops/age/keys.txt in $XDG_CONFIG_HOME or the user config directory).
+func sopsAgeIdentities() ([]age.Identity, error) {
+	var ids []age.Identity
+
+	if k := os.Getenv("SOPS_AGE_KEY"); k != "" {
+		parsed, err := age.ParseIdentities(strings.NewReader(k))
+		if err != nil {
+			return nil, fmt.Errorf("sops: invalid SOPS_AGE_KEY: %w", err)
+		}
+		ids = append(ids, parsed...)
+	}
+
+	path := os.Getenv("SOPS_AGE_KEY_FILE")
+	if path == "" {
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if dir == "" {
+			dir, _ = os.UserConfigDir()
+		}
+		path = filepath.Join(dir, "sops", "age", "keys.txt")
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		parsed, err := age.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("sops: invalid age key file %s: %w", path, err)
+		}
+		ids = append(ids, parsed...)
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("sops: failed to read age key file: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("sops: no age identities found (set SOPS_AGE_KEY_FILE or create %s)", path)
+	}
+	return ids, nil
+}
+
+// isSOPSDotenv reports whether data is a SOPS-encrypted dotenv file.
+func isSOPSDotenv(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte(sopsMetadataKey+"_mac=")) {
+			return true
+		}
+	}
+	return false
+}
+
+// decryptSOPSDotenv returns the plaintext of a SOPS-encrypted dotenv file,
+// as `sops --decrypt` would print it.
+func decryptSOPSDotenv(data []byte) ([]byte, error) {
+	type item struct {
+		key, value string
+		comment    bool
+	}
+
+	var items []item
+	var md sopsMetadata
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSuffix(raw, "\r")
+		if line == "" {
+			continue
+		}
+		if line[0] == '#' {
+			items = append(items, item{value: line[1:], comment: true})
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("sops: invalid dotenv line %q", line)
+		}
+		value = strings.ReplaceAll(value, `\n`, "\n")
+
+		if mdKey, isMeta := strings.CutPrefix(key, sopsMetadataKey+"_"); isMeta {
+			switch {
+			case mdKey == "lastmodified":
+				md.LastModified = value
+			case mdKey == "mac":
+				md.MAC = value
+			case mdKey == "mac_only_encrypted":
+				md.MACOnlyEncrypted, _ = strconv.ParseBool(value)
+			case sopsDotenvAgeRe.MatchString(mdKey):
+				md.Age = append(md.Age, sopsAgeKey{Enc: value})
+			}
+			continue
+		}
+		items = append(items, item{key: key, value: value})
+	}
+
+	d, err := newSOPSDecryptor(md)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for _, it := range items {
+		if it.comment {
+			plaintext, err := d.comment(it.value, nil)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&out, "#%s\n", plaintext)
+			continue
+		}
+		plaintext, _, err := d.leaf(it.value, []string{it.key})
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&out, "%s=%s\n", it.key, strings.ReplaceAll(plaintext, "\n", `\n`))
+	}
+
+	if err := d.verify(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// sopsDocument parses a YAML or JSON document and returns it if it carries
+// SOPS metadata.
+func sopsDocument(data []byte) (*yaml.Node, bool) {
+	if !bytes.Contains(data, []byte(sopsMetadataKey)) {
+		return nil, false
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil, false
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, false
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == sopsMetadataKey && root.Content[i+1].Kind == yaml.MappingNode {
+			return &doc, true
+		}
+	}
+	return nil, false
+}
+
+// decryptSOPSDocument decrypts every value of a document returned by
+// sopsDocument in place and removes its metadata.
+func decryptSOPSDocument(doc *yaml.Node) error {
+	root := doc.Content[0]
+
+	var md sopsMetadata
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == sopsMetadataKey {
+			if err := root.Content[i+1].Decode(&md); err != nil {
+				return fmt.Errorf("sops: invalid metadata: %w", err)
+			}
+			root.Content = append(root.Content[:i:i], root.Content[i+2:]...)
+			break
+		}
+	}
+
+	d, err := newSOPSDecryptor(md)
+	if err != nil {
+		return err
+	}
+
+	// Walk the tree the way SOPS does: mapping keys extend the path used as
+	// additional data, sequence items share their parent's path, and nulls
+	// and comments are not part of the MAC.
+	var walk func(n *yaml.Node, path []string) error
+	walk = func(n *yaml.Node, path []string) error {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if err := walk(n.Content[i+1], append(path, n.Content[i].Value)); err != nil {
+					return err
+				}
+			}
+		case yaml.SequenceNode:
+			for _, item := range n.Content {
+				if err := walk(item, path); err != nil {
+					return err
+				}
+			}
+		case yaml.AliasNode:
+			return fmt.Errorf("sops: aliases are not supported in encrypted files")
+		case yaml.ScalarNode:
+			if n.ShortTag() == "!!null" {
+				return nil
+			}
+			if !sopsValueRe.MatchString(n.Value) {
+				// Unencrypted values enter the MAC in SOPS' canonical form.
+				var v any
+				if err := n.Decode(&v); err != nil {
+					return err
+				}
+				_, _, err := d.leaf(sopsCanonical(v), path)
+				return err
+			}
+			plaintext, typ, err := d.leaf(n.Value, path)
+			if err != nil {
+				return err
+			}
+			n.Value, n.Tag, n.Style = plaintext, "!!str", 0
+			switch typ {
+			case "int":
+				n.Tag = "!!int"
+			case "float":
+				n.Tag = "!!float"
+			case "bool":
+				n.Tag, n.Value = "!!bool", strings.ToLower(plaintext)
+			}
+		}
+		return nil
+	}
+	if err := walk(root, nil); err != nil {
+		return err
+	}
+	return d.verify()
+}
+
+// sopsCanonical formats a decoded YAML scalar the way SOPS hashes it.
+func sopsCanonical(v any) string {
+	switch t := v.(type) {
+	case bool:
+		if t {
+			return "True"
+		}
+		return "False"
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case time.Time:
+		b, _ := t.MarshalText()
+		return string(b)
+	default:
+		return fmt.Sprint(t)
+	}
+}
@@ -0,0 +1,162 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akpatel363/menv/internal/config"
+)
+
+// The files in testdata/sops were encrypted by sops 3.10.2 for the age
+// identity in key.txt. other.txt is an unrelated identity.
+
+// useSOPSKey points the age identity lookup at a file in testdata/sops.
+func useSOPSKey(t *testing.T, name string) {
+	t.Helper()
+	t.Setenv("SOPS_AGE_KEY", "")
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join("testdata", "sops", name))
+}
+
+func readSOPSFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "sops", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecryptSOPSDotenv(t *testing.T) {
+	useSOPSKey(t, "key.txt")
+	data := readSOPSFixture(t, "env.sops")
+	if !isSOPSDotenv(data) {
+		t.Fatal("isSOPSDotenv = false")
+	}
+
+	got, err := decryptSOPSDotenv(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// env.plain is the output of 'sops decrypt', including the comment.
+	if want := readSOPSFixture(t, "env.plain"); string(got) != string(want) {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// CRLF line endings decrypt the same.
+	crlf := strings.ReplaceAll(string(data), "\n", "\r\n")
+	if got2, err := decryptSOPSDotenv([]byte(crlf)); err != nil || string(got2) != string(got) {
+		t.Errorf("CRLF: got %q, %v", got2, err)
+	}
+
+	// The identity can also come from $SOPS_AGE_KEY.
+	t.Setenv("SOPS_AGE_KEY", string(readSOPSFixture(t, "key.txt")))
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+	if _, err := decryptSOPSDotenv(data); err != nil {
+		t.Errorf("SOPS_AGE_KEY: %v", err)
+	}
+
+	if isSOPSDotenv([]byte("A=1\nsops=no\n")) {
+		t.Error("isSOPSDotenv is true for a plain file")
+	}
+}
+
+func TestDecryptSOPSDocument(t *testing.T) {
+	useSOPSKey(t, "key.txt")
+	tests := []struct {
+		name, file, plain, format string
+	}{
+		{"yaml", "secrets.sops.yaml", "plain.yaml", formatYAML},
+		{"json", "secrets.sops.json", "plain.json", formatJSON},
+		{"yaml with encrypted_regex", "partial.sops.yaml", "plain.yaml", formatYAML},
+		{"yaml with mac_only_encrypted", "maconly.sops.yaml", "plain.yaml", formatYAML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := readSOPSFixture(t, tt.file)
+			if _, ok := sopsDocument(data); !ok {
+				t.Fatal("sopsDocument did not detect the file")
+			}
+			got, err := parseStructured(data, tt.format, config.FileRef{Path: tt.file})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := parseStructured(readSOPSFixture(t, tt.plain), tt.format, config.FileRef{Path: tt.plain})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(want) {
+				t.Fatalf("got %d entries %+v, want %d %+v", len(got), got, len(want), want)
+			}
+			for i := range want {
+				if got[i].key != want[i].key || got[i].value != want[i].value {
+					t.Errorf("entry %d = %s=%q, want %s=%q", i, got[i].key, got[i].value, want[i].key, want[i].value)
+				}
+			}
+		})
+	}
+
+	if _, ok := sopsDocument(readSOPSFixture(t, "plain.yaml")); ok {
+		t.Error("sopsDocument detected a plain file")
+	}
+}
+
+func TestSOPSErrors(t *testing.T) {
+	dotenv := string(readSOPSFixture(t, "env.sops"))
+	yamlDoc := string(readSOPSFixture(t, "secrets.sops.yaml"))
+	jsonDoc := string(readSOPSFixture(t, "secrets.sops.json"))
+
+	// encValue returns the encrypted value of key in the dotenv fixture.
+	encValue := func(key string) string {
+		for _, line := range strings.Split(dotenv, "\n") {
+			if v, ok := strings.CutPrefix(line, key+"="); ok {
+				return v
+			}
+		}
+		t.Fatalf("no %s in env.sops", key)
+		return ""
+	}
+	host, password := encValue("DB_HOST"), encValue("DB_PASSWORD")
+	comment, _, _ := strings.Cut(dotenv, "\n") // the encrypted "# database settings"
+
+	tests := []struct {
+		name   string
+		key    string // identity file in testdata/sops, or "" for none
+		data   string
+		format string
+		want   string
+	}{
+		{"dotenv: edited plaintext value", "key.txt", strings.Replace(dotenv, "\nEMPTY=\n", "\nEMPTY=x\n", 1), formatDotenv, "MAC mismatch"},
+		{"dotenv: removed value", "key.txt", strings.Replace(dotenv, "DB_HOST="+host+"\n", "", 1), formatDotenv, "MAC mismatch"},
+		{"dotenv: added value", "key.txt", dotenv + "EXTRA=1\n", formatDotenv, "MAC mismatch"},
+		{"dotenv: value moved to another key", "key.txt", strings.Replace(dotenv, "DB_HOST="+host, "DB_HOST="+password, 1), formatDotenv, "sops: DB_HOST: decryption failed"},
+		{"dotenv: tampered comment", "key.txt", strings.Replace(dotenv, comment, "#"+host, 1), formatDotenv, "sops: comment: decryption failed"},
+		{"dotenv: wrong identity", "other.txt", dotenv, formatDotenv, "none of the local age identities can decrypt this file"},
+		{"dotenv: no identity", "", dotenv, formatDotenv, "no age identities found"},
+		{"yaml: edited plaintext value", "key.txt", strings.Replace(yamlDoc, "nothing: null", "nothing: x", 1), formatYAML, "MAC mismatch"},
+		{"yaml: renamed key", "key.txt", strings.Replace(yamlDoc, "    password:", "    secret:", 1), formatYAML, "sops: db.secret: decryption failed"},
+		{"yaml: wrong identity", "other.txt", yamlDoc, formatYAML, "none of the local age identities can decrypt this file"},
+		{"yaml: no identity", "", yamlDoc, formatYAML, "no age identities found"},
+		{"json: edited value", "key.txt", strings.Replace(jsonDoc, `"features": [`, `"features": ["new",`, 1), formatJSON, "MAC mismatch"},
+		{"json: wrong identity", "other.txt", jsonDoc, formatJSON, "none of the local age identities can decrypt this file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.key == "" {
+				useSOPSKey(t, "missing.txt")
+			} else {
+				useSOPSKey(t, tt.key)
+			}
+			var err error
+			if tt.format == formatDotenv {
+				_, err = decryptSOPSDotenv([]byte(tt.data))
+			} else {
+				_, err = parseStructured([]byte(tt.data), tt.format, config.FileRef{})
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
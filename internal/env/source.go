@@ -0,0 +1,80 @@
+package env
+
+import (
+	"fmt"
+)
+
+// SourceKind identifies where a variable's value came from.
+type SourceKind string
+
+const (
+	SourceFile     SourceKind = "file"
+	SourceOverride SourceKind = "override"
+	SourceOS       SourceKind = "os"
+	SourceDefault  SourceKind = "schema default"
+	SourceVault    SourceKind = "vault"
+	SourceSSM      SourceKind = "ssm"
+)
+
+// Source describes the origin of a single value.
+type Source struct {
+	Kind SourceKind
+	// File and Line are set for SourceFile. For secret stores such as
+	// SourceVault, File names the secret.
+	File string
+	Line int
+	// Layer names the env the value was inherited from, if any.
+	Layer string
+}
+
+// String returns a short human-readable description, e.g. ".env.prod:12"
+// or "override (from dev)".
+func (s Source) String() string {
+	desc := string(s.Kind)
+	if s.Kind == SourceFile {
+		desc = s.File
+		if s.Line != 0 {
+			desc = fmt.Sprintf("%s:%d", s.File, s.Line)
+		}
+	} else if s.File != "" {
+		desc += ":" + s.File
+	}
+	if s.Layer != "" {
+		desc += " (from " + s.Layer + ")"
+	}
+	return desc
+}
+
+// Var is a resolved variable along with the definitions it replaced.
+type Var struct {
+	Value  string
+	Source Source
+	// Shadowed holds earlier definitions of the same key, most recent first.
+	// The OS environment, if it defines the key and passes it on (see
+	// BaseEnv), is always last.
+	Shadowed []Var
+}
+
+// Vars is the result of LoadEnv, keyed by variable name.
+type Vars map[string]Var
+
+// Values returns the plain key=value map.
+func (vs Vars) Values() map[string]string {
+	m := make(map[string]string, len(vs))
+	for k, v := range vs {
+		m[k] = v.Value
+	}
+	return m
+}
+
+// set records a new definition of key, pushing any previous one, or else
+// its value in osEnv, onto the shadowed chain.
+func (vs Vars) set(key, value string, src Source, osEnv map[string]string) {
+	var shadowed []Var
+	if prev, ok := vs[key]; ok {
+		shadowed = append([]Var{{Value: prev.Value, Source: prev.Source}}, prev.Shadowed...)
+	} else if v, ok := osEnv[key]; ok {
+		shadowed = []Var{{Value: v, Source: Source{Kind: SourceOS}}}
+	}
+	vs[key] = Var{Value: value, Source: src, Shadowed: shadowed}
+}
@@ -0,0 +1,53 @@
+package env
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/provider"
+)
+
+func ssmParameters(src config.SSMSource, lookup lookupFunc) provider.SSMParameters {
+	return provider.SSMParameters{
+		Path:     src.Path,
+		Region:   src.Region,
+		Profile:  src.Profile,
+		Endpoint: src.Endpoint,
+		Getenv: func(name string) string {
+			v, _ := lookup(name)
+			return v
+		},
+	}
+}
+
+// ssmValues reads the parameters under src.Path and returns the variables
+// they provide: the parameters listed in src.Keys under their env var
+// names, or every parameter under a name derived from its path. AWS
+// settings such as AWS_PROFILE are looked up with lookup, so they can come
+// from earlier layers as well as the OS environment.
+func ssmValues(src config.SSMSource, lookup lookupFunc) (map[string]string, error) {
+	if src.Path == "" {
+		return nil, fmt.Errorf("ssm: path is required")
+	}
+	params, err := provider.Default.SSM(ssmParameters(src, lookup))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(params))
+	if len(src.Keys) == 0 {
+		for name, v := range params {
+			values[flattenKey(strings.Split(name, "/"), "_", "upper")] = v
+		}
+		return values, nil
+	}
+	for name, param := range src.Keys {
+		v, ok := params[strings.Trim(param, "/")]
+		if !ok {
+			return nil, fmt.Errorf("ssm %s: no parameter %q (for %s)", src.Path, param, name)
+		}
+		values[name] = v
+	}
+	return values, nil
+}
@@ -0,0 +1,321 @@
+package env
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akpatel363/menv/internal/config"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	formatDotenv = "dotenv"
+	formatJSON   = "json"
+	formatYAML   = "yaml"
+	formatTOML   = "toml"
+)
+
+// fileFormat returns the format of ref, from its explicit Format field or
+// its extension, ignoring a trailing .enc.
+func fileFormat(ref config.FileRef) (string, error) {
+	switch f := strings.ToLower(ref.Format); f {
+	case formatDotenv, formatJSON, formatYAML, formatTOML:
+		return f, nil
+	case "yml":
+		return formatYAML, nil
+	case "env":
+		return formatDotenv, nil
+	case "":
+	default:
+		return "", fmt.Errorf("%s: unknown format %q (expected dotenv, json, yaml or toml)", ref.Path, ref.Format)
+	}
+
+	switch strings.ToLower(filepath.Ext(strings.TrimSuffix(ref.Path, ".enc"))) {
+	case ".json":
+		return formatJSON, nil
+	case ".yaml", ".yml":
+		return formatYAML, nil
+	case ".toml":
+		return formatTOML, nil
+	}
+	return formatDotenv, nil
+}
+
+// parseStructured reads a JSON, YAML or TOML document and flattens it into
+// entries. Nested keys and array indexes are joined with ref.Separator
+// and cased according to ref.Case, so {"db": {"host": "x"}} becomes DB_HOST=x.
+func parseStructured(data []byte, format string, ref config.FileRef) ([]entry, error) {
+	var entries []entry
+	emit := func(path []string, value string, line int) {
+		entries = append(entries, entry{
+			key:        flattenKey(path, ref.Separator, ref.Case),
+			value:      value,
+			line:       line,
+			endLine:    line,
+			structured: true,
+		})
+	}
+
+	if format == formatJSON || format == formatYAML {
+		if doc, ok := sopsDocument(data); ok {
+			if err := decryptSOPSDocument(doc); err != nil {
+				return nil, err
+			}
+			walkYAMLNode(doc, emit)
+			return entries, nil
+		}
+	}
+
+	var err error
+	switch format {
+	case formatJSON:
+		err = walkJSON(data, emit)
+	case formatYAML:
+		err = walkYAML(data, emit)
+	case formatTOML:
+		err = walkTOML(data, emit)
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	return entries, err
+}
+
+// flattenKey joins a nested key path into a single env var name. Characters
+// that are not valid in shell identifiers are replaced with underscores.
+func flattenKey(path []string, sep, keyCase string) string {
+	if sep == "" {
+		sep = "_"
+	}
+	parts := make([]string, len(path))
+	for i, p := range path {
+		parts[i] = strings.Map(func(r rune) rune {
+			if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+				return r
+			}
+			return '_'
+		}, p)
+	}
+	key := strings.Join(parts, sep)
+
+	switch strings.ToLower(keyCase) {
+	case "lower":
+		return strings.ToLower(key)
+	case "preserve":
+		return key
+	default:
+		return strings.ToUpper(key)
+	}
+}
+
+type emitFunc func(path []string, value string, line int)
+
+// walkJSON emits every scalar in a JSON document in document order.
+func walkJSON(data []byte, emit emitFunc) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	lineAt := func() int {
+		return 1 + bytes.Count(data[:dec.InputOffset()], []byte("\n"))
+	}
+
+	var walk func(path []string) error
+	walk = func(path []string) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				for dec.More() {
+					keyTok, err := dec.Token()
+					if err != nil {
+						return err
+					}
+					if err := walk(append(path, keyTok.(string))); err != nil {
+						return err
+					}
+				}
+			case '[':
+				for i := 0; dec.More(); i++ {
+					if err := walk(append(path, strconv.Itoa(i))); err != nil {
+						return err
+					}
+				}
+			}
+			_, err := dec.Token() // closing delimiter
+			return err
+		case string:
+			emit(path, t, lineAt())
+		case json.Number:
+			emit(path, t.String(), lineAt())
+		case bool:
+			emit(path, strconv.FormatBool(t), lineAt())
+		case nil:
+			emit(path, "", lineAt())
+		}
+		return nil
+	}
+
+	if err := walk(nil); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err == nil {
+		return fmt.Errorf("unexpected data after top-level value")
+	}
+	return nil
+}
+
+// walkYAML emits every scalar in a YAML document in document order.
+func walkYAML(data []byte, emit emitFunc) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	walkYAMLNode(&doc, emit)
+	return nil
+}
+
+// walkYAMLNode emits every scalar under a parsed YAML document node.
+func walkYAMLNode(doc *yaml.Node, emit emitFunc) {
+	if len(doc.Content) == 0 {
+		return
+	}
+
+	var walk func(n *yaml.Node, path []string, line int)
+	walk = func(n *yaml.Node, path []string, line int) {
+		switch n.Kind {
+		case yaml.AliasNode:
+			walk(n.Alias, path, line)
+		case yaml.MappingNode:
+			for _, p := range mappingPairs(n) {
+				walk(p.value, append(path, p.key.Value), p.key.Line)
+			}
+		case yaml.SequenceNode:
+			for i, item := range n.Content {
+				walk(item, append(path, strconv.Itoa(i)), item.Line)
+			}
+		case yaml.ScalarNode:
+			value := n.Value
+			if n.ShortTag() == "!!null" {
+				value = ""
+			}
+			emit(path, value, line)
+		}
+	}
+	walk(doc.Content[0], nil, doc.Content[0].Line)
+}
+
+// yamlPair is a key and its value in a YAML mapping.
+type yamlPair struct {
+	key, value *yaml.Node
+}
+
+// mappingPairs returns the pairs of a mapping node, with the mappings that
+// merge keys (<<: *defaults, or a sequence of aliases) refer to expanded in
+// their place. Explicit keys win over merged ones, and earlier merged
+// mappings over later ones.
+func mappingPairs(n *yaml.Node) []yamlPair {
+	explicit := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if k := n.Content[i]; k.ShortTag() != "!!merge" {
+			explicit[k.Value] = true
+		}
+	}
+
+	var pairs []yamlPair
+	merged := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.ShortTag() != "!!merge" {
+			pairs = append(pairs, yamlPair{k, v})
+			continue
+		}
+		sources := []*yaml.Node{v}
+		if resolveAlias(v).Kind == yaml.SequenceNode {
+			sources = resolveAlias(v).Content
+		}
+		for _, src := range sources {
+			if src = resolveAlias(src); src.Kind != yaml.MappingNode {
+				continue
+			}
+			for _, p := range mappingPairs(src) {
+				if !explicit[p.key.Value] && !merged[p.key.Value] {
+					merged[p.key.Value] = true
+					pairs = append(pairs, p)
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// resolveAlias returns the node an alias refers to, or n itself.
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// walkTOML emits every value in a TOML document, with keys in sorted order
+// within each table. TOML positions are not tracked, so lines are zero.
+func walkTOML(data []byte, emit emitFunc) error {
+	var doc map[string]any
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	var walk func(v any, path []string)
+	walk = func(v any, path []string) {
+		switch t := v.(type) {
+		case map[string]any:
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(t[k], append(path, k))
+			}
+		case []map[string]any:
+			for i, item := range t {
+				walk(item, append(path, strconv.Itoa(i)))
+			}
+		case []any:
+			for i, item := range t {
+				walk(item, append(path, strconv.Itoa(i)))
+			}
+		case time.Time:
+			emit(path, tomlTime(t), 0)
+		default:
+			emit(path, fmt.Sprint(t), 0)
+		}
+	}
+	walk(doc, nil)
+	return nil
+}
+
+// tomlTime formats a TOML date-time the way it was written: local
+// date-times, dates and times, which the decoder marks with zones of these
+// names, are formatted without an offset.
+func tomlTime(t time.Time) string {
+	switch t.Location().String() {
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,261 @@
+package env
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/akpatel363/menv/internal/config"
+)
+
+func TestFileFormat(t *testing.T) {
+	tests := []struct {
+		ref     config.FileRef
+		want    string
+		wantErr string
+	}{
+		{ref: config.FileRef{Path: ".env"}, want: "dotenv"},
+		{ref: config.FileRef{Path: ".env.local"}, want: "dotenv"},
+		{ref: config.FileRef{Path: "config.json"}, want: "json"},
+		{ref: config.FileRef{Path: "config.YAML"}, want: "yaml"},
+		{ref: config.FileRef{Path: "config.yml"}, want: "yaml"},
+		{ref: config.FileRef{Path: "config.toml"}, want: "toml"},
+		{ref: config.FileRef{Path: "secrets.json.enc"}, want: "json"},
+		{ref: config.FileRef{Path: ".env.enc"}, want: "dotenv"},
+		{ref: config.FileRef{Path: "settings", Format: "yml"}, want: "yaml"},
+		{ref: config.FileRef{Path: "settings.json", Format: "env"}, want: "dotenv"},
+		{ref: config.FileRef{Path: "x", Format: "TOML"}, want: "toml"},
+		{ref: config.FileRef{Path: "x", Format: "ini"}, wantErr: `x: unknown format "ini"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref.Path+"/"+tt.ref.Format, func(t *testing.T) {
+			got, err := fileFormat(tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("fileFormat = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlattenKey(t *testing.T) {
+	tests := []struct {
+		path    []string
+		sep     string
+		keyCase string
+		want    string
+	}{
+		{[]string{"db", "host"}, "", "", "DB_HOST"},
+		{[]string{"db", "host"}, "__", "", "DB__HOST"},
+		{[]string{"db", "host"}, "_", "lower", "db_host"},
+		{[]string{"Db", "HostName"}, "_", "preserve", "Db_HostName"},
+		{[]string{"Db", "HostName"}, "_", "UPPER", "DB_HOSTNAME"},
+		{[]string{"servers", "0", "url"}, "", "", "SERVERS_0_URL"},
+		{[]string{"api-gateway", "base.url"}, "", "", "API_GATEWAY_BASE_URL"},
+		{[]string{"héllo wörld"}, "", "preserve", "h_llo_w_rld"},
+	}
+	for _, tt := range tests {
+		if got := flattenKey(tt.path, tt.sep, tt.keyCase); got != tt.want {
+			t.Errorf("flattenKey(%q, %q, %q) = %q, want %q", tt.path, tt.sep, tt.keyCase, got, tt.want)
+		}
+	}
+}
+
+// keyValue is an expected flattened entry.
+type keyValue struct {
+	key, value string
+}
+
+func TestParseStructured(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		ref    config.FileRef
+		src    string
+		want   []keyValue
+	}{
+		{
+			name:   "json",
+			format: formatJSON,
+			src: `{
+  "db": {"host": "localhost", "port": 5432, "ssl": false},
+  "servers": [{"url": "a"}, {"url": "b"}],
+  "empty": null,
+  "big": 12345678901234567890
+}`,
+			want: []keyValue{
+				{"DB_HOST", "localhost"}, {"DB_PORT", "5432"}, {"DB_SSL", "false"},
+				{"SERVERS_0_URL", "a"}, {"SERVERS_1_URL", "b"},
+				{"EMPTY", ""}, {"BIG", "12345678901234567890"},
+			},
+		},
+		{
+			name:   "json separator and case",
+			format: formatJSON,
+			ref:    config.FileRef{Separator: "__", Case: "lower"},
+			src:    `{"Db": {"Host": "x"}}`,
+			want:   []keyValue{{"db__host", "x"}},
+		},
+		{
+			name:   "yaml",
+			format: formatYAML,
+			src: `db:
+  host: localhost
+  port: 5432
+tags: [a, b]
+empty: ~
+also_empty:
+multi: |
+  line1
+  line2
+`,
+			want: []keyValue{
+				{"DB_HOST", "localhost"}, {"DB_PORT", "5432"},
+				{"TAGS_0", "a"}, {"TAGS_1", "b"},
+				{"EMPTY", ""}, {"ALSO_EMPTY", ""}, {"MULTI", "line1\nline2\n"},
+			},
+		},
+		{
+			name:   "yaml merge key",
+			format: formatYAML,
+			src: `defaults: &defaults
+  host: localhost
+  port: 5432
+prod:
+  <<: *defaults
+  host: db.prod
+`,
+			want: []keyValue{
+				{"DEFAULTS_HOST", "localhost"}, {"DEFAULTS_PORT", "5432"},
+				{"PROD_PORT", "5432"}, {"PROD_HOST", "db.prod"},
+			},
+		},
+		{
+			name:   "yaml merge key sequence",
+			format: formatYAML,
+			src: `a: &a {host: a, port: 1}
+b: &b {host: b, user: bob, nested: {<<: *a}}
+c:
+  <<: [*a, *b]
+  port: 3
+`,
+			want: []keyValue{
+				{"A_HOST", "a"}, {"A_PORT", "1"},
+				{"B_HOST", "b"}, {"B_USER", "bob"}, {"B_NESTED_HOST", "a"}, {"B_NESTED_PORT", "1"},
+				{"C_HOST", "a"}, {"C_USER", "bob"}, {"C_NESTED_HOST", "a"}, {"C_NESTED_PORT", "1"}, {"C_PORT", "3"},
+			},
+		},
+		{
+			name:   "yaml quoted merge key is a plain key",
+			format: formatYAML,
+			src:    `"<<": x`,
+			want:   []keyValue{{"__", "x"}},
+		},
+		{
+			name:   "toml",
+			format: formatTOML,
+			src: `title = "app"
+port = 8080
+ratio = 0.5
+debug = true
+tags = ["a", "b"]
+created = 1979-05-27T07:32:00-08:00
+local = 1979-05-27T07:32:00
+precise = 1979-05-27T07:32:00.999999Z
+day = 1979-05-27
+at = 07:32:00
+
+[db]
+host = "localhost"
+
+[[servers]]
+url = "a"
+
+[[servers]]
+url = "b"
+`,
+			want: []keyValue{
+				{"AT", "07:32:00"},
+				{"CREATED", "1979-05-27T07:32:00-08:00"},
+				{"DAY", "1979-05-27"},
+				{"DB_HOST", "localhost"},
+				{"DEBUG", "true"},
+				{"LOCAL", "1979-05-27T07:32:00"},
+				{"PORT", "8080"},
+				{"PRECISE", "1979-05-27T07:32:00.999999Z"},
+				{"RATIO", "0.5"},
+				{"SERVERS_0_URL", "a"}, {"SERVERS_1_URL", "b"},
+				{"TAGS_0", "a"}, {"TAGS_1", "b"},
+				{"TITLE", "app"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := parseStructured([]byte(tt.src), tt.format, tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []keyValue
+			for _, e := range entries {
+				if !e.structured {
+					t.Errorf("%s is not marked structured", e.key)
+				}
+				got = append(got, keyValue{e.key, e.value})
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseStructuredErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		src    string
+		want   string
+	}{
+		{"trailing json data", formatJSON, `{"a": 1} {"b": 2}`, "unexpected data after top-level value"},
+		{"truncated json", formatJSON, `{"a": `, "EOF"},
+		{"invalid yaml", formatYAML, "a: [1, 2", "yaml"},
+		{"invalid toml", formatTOML, "a = ", "toml"},
+		{"unsupported", "ini", "a=1", `unsupported format "ini"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseStructured([]byte(tt.src), tt.format, config.FileRef{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStructuredLines(t *testing.T) {
+	entries, err := parseStructured([]byte("{\n  \"a\": 1,\n  \"b\": {\n    \"c\": \"x\"\n  }\n}\n"), formatJSON, config.FileRef{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].line != 2 || entries[1].line != 4 {
+		t.Errorf("lines = %d, %d; want 2, 4", entries[0].line, entries[1].line)
+	}
+
+	entries, err = parseStructured([]byte("base: &b\n  x: 1\nchild:\n  <<: *b\n"), formatYAML, config.FileRef{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[1].key != "CHILD_X" || entries[1].line != 2 {
+		t.Errorf("merged entry %s on line %d, want CHILD_X on line 2 where it is defined", entries[1].key, entries[1].line)
+	}
+}
@@ -0,0 +1,55 @@
+package env
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/akpatel363/menv/internal/config"
+	"github.com/akpatel363/menv/internal/provider"
+)
+
+func vaultSecret(src config.VaultSource) provider.VaultSecret {
+	return provider.VaultSecret{
+		Address:   src.Address,
+		Mount:     src.Mount,
+		Path:      src.Path,
+		TokenFile: src.TokenFile,
+	}
+}
+
+// vaultValues reads the secret named by src and returns the variables it
+// provides: the fields listed in src.Keys under their env var names, or
+// every field when no keys are listed.
+func vaultValues(src config.VaultSource) (map[string]string, error) {
+	if src.Path == "" {
+		return nil, fmt.Errorf("vault: path is required")
+	}
+	secret := vaultSecret(src)
+	fields, err := provider.Default.Vault(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(src.Keys) == 0 {
+		return fields, nil
+	}
+
+	values := make(map[string]string, len(src.Keys))
+	for name, field := range src.Keys {
+		v, ok := fields[field]
+		if !ok {
+			return nil, fmt.Errorf("vault %s: no field %q (for %s)", secret, field, name)
+		}
+		values[name] = v
+	}
+	return values, nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package env
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akpatel363/menv/internal/config"
+)
+
+func TestLoadEnvVault(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "test-token")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/env-test/prod" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"db_password":"hunter2","api_key":"k-123","port":8080}}}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		keys map[string]string
+		want map[string]string
+	}{
+		{
+			name: "every field under its own name",
+			want: map[string]string{"db_password": "hunter2", "api_key": "k-123", "port": "8080"},
+		},
+		{
+			name: "mapped keys",
+			keys: map[string]string{"DB_PASSWORD": "db_password", "PORT": "port"},
+			want: map[string]string{"DB_PASSWORD": "hunter2", "PORT": "8080"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vars := loadTestEnv(t, map[string]string{".env": "PORT=1\nOTHER=x\n"}, config.Env{
+				Files: []config.FileRef{{Path: ".env"}},
+				Vault: &config.VaultSource{Address: srv.URL, Path: "env-test/prod", Keys: tt.keys},
+			})
+			for k, v := range tt.want {
+				if got := vars[k]; got.Value != v || got.Source.Kind != SourceVault {
+					t.Errorf("%s = %q from %s, want %q from vault", k, got.Value, got.Source, v)
+				}
+			}
+			if got := vars["OTHER"].Value; got != "x" {
+				t.Errorf("OTHER = %q, want the file value", got)
+			}
+			for k := range vars {
+				if _, ok := tt.want[k]; !ok && k != "OTHER" && k != "PORT" {
+					t.Errorf("unexpected variable %s", k)
+				}
+			}
+		})
+	}
+
+	t.Run("missing field", func(t *testing.T) {
+		_, err := vaultValues(config.VaultSource{Address: srv.URL, Path: "env-test/prod", Keys: map[string]string{"TOKEN": "token"}})
+		if err == nil || !strings.Contains(err.Error(), `no field "token" (for TOKEN)`) {
+			t.Errorf("error = %v, want a missing field error", err)
+		}
+	})
+}
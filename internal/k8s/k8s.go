@@ -0,0 +1,174 @@
+// Package k8s converts between env variables and Kubernetes ConfigMap and
+// Secret manifests.
+package k8s
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// keyPattern matches valid ConfigMap and Secret keys.
+	keyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	// namePattern matches DNS subdomain names, as required for object names.
+	namePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+	// nameInvalid matches runs of characters not allowed in object names.
+	nameInvalid = regexp.MustCompile(`[^a-z0-9.-]+`)
+)
+
+type objectMeta struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type object struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   objectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+	BinaryData map[string]string `yaml:"binaryData,omitempty"`
+	Items      []object          `yaml:"items,omitempty"`
+}
+
+// Options describes the objects written by Write.
+type Options struct {
+	Name      string
+	Namespace string
+	Labels    map[string]string
+}
+
+// Name turns s, such as "my_api-Prod", into a valid object name ("my-api-prod").
+func Name(s string) string {
+	s = nameInvalid.ReplaceAllString(strings.ToLower(s), "-")
+	s = strings.Trim(s, "-.")
+	if len(s) > 253 {
+		s = strings.Trim(s[:253], "-.")
+	}
+	return s
+}
+
+// Write writes a ConfigMap holding plain and a Secret holding secret (base64
+// encoded under data) as a multi-document YAML stream. Either object is
+// omitted when it would be empty.
+func Write(w io.Writer, opts Options, plain, secret map[string]string) error {
+	if !namePattern.MatchString(opts.Name) || len(opts.Name) > 253 {
+		return fmt.Errorf("invalid name %q (use lowercase letters, digits, '-' and '.')", opts.Name)
+	}
+	for _, m := range []map[string]string{plain, secret} {
+		for k := range m {
+			if !keyPattern.MatchString(k) {
+				return fmt.Errorf("key %q is not valid in a ConfigMap or Secret", k)
+			}
+		}
+	}
+
+	meta := objectMeta{Name: opts.Name, Namespace: opts.Namespace, Labels: opts.Labels}
+	var objects []object
+	if len(plain) > 0 {
+		objects = append(objects, object{APIVersion: "v1", Kind: "ConfigMap", Metadata: meta, Data: plain})
+	}
+	if len(secret) > 0 {
+		data := make(map[string]string, len(secret))
+		for k, v := range secret {
+			data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+		}
+		objects = append(objects, object{APIVersion: "v1", Kind: "Secret", Metadata: meta, Type: "Opaque", Data: data})
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	for _, o := range objects {
+		if err := enc.Encode(o); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// Manifest is the content read from ConfigMap and Secret manifests.
+type Manifest struct {
+	// Name is the name of the first ConfigMap or Secret found.
+	Name string
+	// Plain holds ConfigMap data; Secret holds decoded Secret data and stringData.
+	Plain  map[string]string
+	Secret map[string]string
+	// Warnings lists what was skipped, such as other kinds of object.
+	Warnings []string
+}
+
+// Read parses a YAML stream of ConfigMaps and Secrets, including ones
+// wrapped in a List, and merges their data. Later objects take precedence
+// over earlier ones for the same key.
+func Read(data []byte) (*Manifest, error) {
+	m := &Manifest{Plain: make(map[string]string), Secret: make(map[string]string)}
+
+	var add func(o object) error
+	add = func(o object) error {
+		switch o.Kind {
+		case "List", "ConfigMapList", "SecretList":
+			for _, item := range o.Items {
+				if err := add(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		case "ConfigMap":
+			for k, v := range o.Data {
+				m.Plain[k] = v
+			}
+			if len(o.BinaryData) > 0 {
+				m.Warnings = append(m.Warnings, fmt.Sprintf("ConfigMap %s: skipped %d binaryData key(s)", o.Metadata.Name, len(o.BinaryData)))
+			}
+		case "Secret":
+			for k, v := range o.Data {
+				decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v))
+				if err != nil {
+					return fmt.Errorf("Secret %s: key %s is not valid base64", o.Metadata.Name, k)
+				}
+				m.Secret[k] = string(decoded)
+			}
+			for k, v := range o.StringData {
+				m.Secret[k] = v
+			}
+		case "":
+			return nil
+		default:
+			m.Warnings = append(m.Warnings, fmt.Sprintf("skipped %s %s", o.Kind, o.Metadata.Name))
+			return nil
+		}
+		if m.Name == "" {
+			m.Name = o.Metadata.Name
+		}
+		return nil
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var o object
+		err := dec.Decode(&o)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := add(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(m.Plain) == 0 && len(m.Secret) == 0 {
+		return nil, fmt.Errorf("no ConfigMap or Secret data found")
+	}
+	return m, nil
+}
@@ -0,0 +1,218 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	opts := Options{Name: "my-app-prod", Namespace: "apps", Labels: map[string]string{"app": "my-app", "env": "prod"}}
+	plain := map[string]string{"LOG_LEVEL": "info", "MULTI": "a\nb", "PORT": "8080"}
+	secret := map[string]string{"DB_PASSWORD": "s3cr3t", "EMPTY": ""}
+
+	var b strings.Builder
+	if err := Write(&b, opts, plain, secret); err != nil {
+		t.Fatal(err)
+	}
+	want := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-app-prod
+  namespace: apps
+  labels:
+    app: my-app
+    env: prod
+data:
+  LOG_LEVEL: info
+  MULTI: |-
+    a
+    b
+  PORT: "8080"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: my-app-prod
+  namespace: apps
+  labels:
+    app: my-app
+    env: prod
+type: Opaque
+data:
+  DB_PASSWORD: czNjcjN0
+  EMPTY: ""
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+
+	// Empty objects are omitted.
+	b.Reset()
+	if err := Write(&b, Options{Name: "x"}, nil, map[string]string{"K": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "ConfigMap") || strings.Contains(b.String(), "namespace") || !strings.Contains(b.String(), "kind: Secret") {
+		t.Errorf("secret only:\n%s", b.String())
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		plain  map[string]string
+		secret map[string]string
+		want   string
+	}{
+		{"uppercase name", Options{Name: "My-App"}, map[string]string{"K": "v"}, nil, `invalid name "My-App"`},
+		{"empty name", Options{}, map[string]string{"K": "v"}, nil, `invalid name ""`},
+		{"long name", Options{Name: strings.Repeat("a", 254)}, map[string]string{"K": "v"}, nil, "invalid name"},
+		{"invalid plain key", Options{Name: "x"}, map[string]string{"A B": "v"}, nil, `key "A B" is not valid`},
+		{"invalid secret key", Options{Name: "x"}, nil, map[string]string{"A/B": "v"}, `key "A/B" is not valid`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			err := Write(&b, tt.opts, tt.plain, tt.secret)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+			if b.Len() > 0 {
+				t.Errorf("partial output:\n%s", b.String())
+			}
+		})
+	}
+}
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"my_api-Prod":                   "my-api-prod",
+		"--Web App!!--":                 "web-app",
+		"a.b":                           "a.b",
+		"":                              "",
+		strings.Repeat("x", 252) + "-y": strings.Repeat("x", 252),
+	}
+	for in, want := range tests {
+		if got := Name(in); got != want {
+			t.Errorf("Name(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	data := `# exported with kubectl
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: api-prod
+data:
+  LOG_LEVEL: info
+  SHARED: from-configmap
+binaryData:
+  logo.png: iVBORw0KGgo=
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api-prod
+type: Opaque
+data:
+  DB_PASSWORD: czNjcjN0
+  WRAPPED: |
+    bXVsdGkKbGluZQ==
+  BINARY: AP8=
+stringData:
+  API_KEY: plain-text
+  DB_PASSWORD: overridden
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: api-extra
+    data:
+      SHARED: from-list
+      EXTRA: "1"
+  - apiVersion: v1
+    kind: Secret
+    metadata:
+      name: api-extra
+    stringData:
+      TOKEN: t0k3n
+---
+`
+	m, err := Read([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "api-prod" {
+		t.Errorf("Name = %q, want the first object's", m.Name)
+	}
+	wantPlain := map[string]string{"LOG_LEVEL": "info", "SHARED": "from-list", "EXTRA": "1"}
+	wantSecret := map[string]string{"DB_PASSWORD": "overridden", "WRAPPED": "multi\nline", "BINARY": "\x00\xff", "API_KEY": "plain-text", "TOKEN": "t0k3n"}
+	for name, pair := range map[string][2]map[string]string{"Plain": {m.Plain, wantPlain}, "Secret": {m.Secret, wantSecret}} {
+		got, want := pair[0], pair[1]
+		if len(got) != len(want) {
+			t.Errorf("%s = %q, want %q", name, got, want)
+			continue
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s[%s] = %q, want %q", name, k, got[k], v)
+			}
+		}
+	}
+	wantWarnings := []string{"ConfigMap api-prod: skipped 1 binaryData key(s)", "skipped Deployment api"}
+	if strings.Join(m.Warnings, "\n") != strings.Join(wantWarnings, "\n") {
+		t.Errorf("Warnings = %q, want %q", m.Warnings, wantWarnings)
+	}
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	plain := map[string]string{"LOG_LEVEL": "info", "MULTI": "a\nb", "PORT": "8080"}
+	secret := map[string]string{"DB_PASSWORD": "s3cr3t: 'quoted'", "EMPTY": ""}
+	var b strings.Builder
+	if err := Write(&b, Options{Name: "x"}, plain, secret); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Read([]byte(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range plain {
+		if m.Plain[k] != v {
+			t.Errorf("Plain[%s] = %q, want %q", k, m.Plain[k], v)
+		}
+	}
+	for k, v := range secret {
+		if got, ok := m.Secret[k]; !ok || got != v {
+			t.Errorf("Secret[%s] = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"invalid base64", "kind: Secret\nmetadata: {name: s}\ndata: {K: '%%%'}\n", "Secret s: key K is not valid base64"},
+		{"no data", "kind: Deployment\nmetadata: {name: d}\n", "no ConfigMap or Secret data found"},
+		{"empty", "", "no ConfigMap or Secret data found"},
+		{"invalid YAML", "kind: [\n", "yaml:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsCredentials are static AWS credentials.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// awsSettings resolves the region and credentials to use, the way the AWS
+// CLI does for static credentials. getenv reads the environment; profile
+// and region, when set, take precedence over it.
+//
+// Credentials come from $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY unless
+// a profile is named explicitly, and otherwise from the profile (profile,
+// $AWS_PROFILE or "default") in the shared credentials and config files.
+// Region comes from region, $AWS_REGION, $AWS_DEFAULT_REGION or the profile.
+func awsSettings(getenv func(string) string, profile, region string) (awsCredentials, string, error) {
+	explicit := profile != ""
+	if profile == "" {
+		profile = getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	home, _ := os.UserHomeDir()
+	credsFile := getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credsFile == "" {
+		credsFile = filepath.Join(home, ".aws", "credentials")
+	}
+	configFile := getenv("AWS_CONFIG_FILE")
+	if configFile == "" {
+		configFile = filepath.Join(home, ".aws", "config")
+	}
+
+	configSection := "profile " + profile
+	if profile == "default" {
+		configSection = "default"
+	}
+	cfg, err := readINISection(configFile, configSection)
+	if err != nil {
+		return awsCredentials{}, "", err
+	}
+
+	if region == "" {
+		region = getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = cfg["region"]
+	}
+	if region == "" {
+		return awsCredentials{}, "", fmt.Errorf("no AWS region (set region, AWS_REGION, or region in profile %q)", profile)
+	}
+
+	if !explicit && getenv("AWS_ACCESS_KEY_ID") != "" {
+		return awsCredentials{
+			AccessKeyID:     getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    getenv("AWS_SESSION_TOKEN"),
+		}, region, nil
+	}
+
+	creds, err := readINISection(credsFile, profile)
+	if err != nil {
+		return awsCredentials{}, "", err
+	}
+	// The config file may hold credentials too; the credentials file wins.
+	for k, v := range cfg {
+		if _, ok := creds[k]; !ok {
+			creds[k] = v
+		}
+	}
+	if creds["aws_access_key_id"] == "" || creds["aws_secret_access_key"] == "" {
+		return awsCredentials{}, "", fmt.Errorf("no AWS credentials for profile %q (only static keys are supported; for SSO or assumed roles, export AWS_ACCESS_KEY_ID and friends first)", profile)
+	}
+	return awsCredentials{
+		AccessKeyID:     creds["aws_access_key_id"],
+		SecretAccessKey: creds["aws_secret_access_key"],
+		SessionToken:    creds["aws_session_token"],
+	}, region, nil
+}
+
+// readINISection returns the key/value pairs of one section of an AWS
+// shared config or credentials file. A missing file yields no values.
+func readINISection(path, section string) (map[string]string, error) {
+	values := make(map[string]string)
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return values, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	in := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+		case line[0] == '[':
+			in = strings.TrimSpace(strings.Trim(line, "[]")) == section
+		case in:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				values[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return values, sc.Err()
+}
+
+// signAWSRequest signs req with AWS Signature Version 4. body must be the
+// request body, which is hashed into the signature.
+func signAWSRequest(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
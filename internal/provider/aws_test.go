@@ -0,0 +1,172 @@
+package provider
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exampleCreds are the credentials used by the examples in the AWS
+// Signature Version 4 documentation and test suite.
+var exampleCreds = awsCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+var exampleTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSignAWSRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		headers map[string]string
+		body    string
+		region  string
+		service string
+		want    string
+	}{
+		{
+			// get-vanilla from the AWS SigV4 test suite.
+			name:    "get vanilla",
+			method:  http.MethodGet,
+			url:     "https://example.amazonaws.com/",
+			region:  "us-east-1",
+			service: "service",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, " +
+				"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			// The IAM ListUsers example from the SigV4 documentation.
+			name:    "query and content type",
+			method:  http.MethodGet,
+			url:     "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8"},
+			region:  "us-east-1",
+			service: "iam",
+			want: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+				"SignedHeaders=content-type;host;x-amz-date, " +
+				"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			signAWSRequest(req, []byte(tt.body), exampleCreds, tt.region, tt.service, exampleTime)
+
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("Authorization =\n  %s\nwant\n  %s", got, tt.want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", got)
+			}
+		})
+	}
+}
+
+func TestSignAWSRequestSessionToken(t *testing.T) {
+	creds := exampleCreds
+	creds.SessionToken = "session-token"
+	req, _ := http.NewRequest(http.MethodPost, "https://ssm.eu-west-1.amazonaws.com/", nil)
+	signAWSRequest(req, []byte("{}"), creds, "eu-west-1", "ssm", exampleTime)
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+	if auth := req.Header.Get("Authorization"); !strings.Contains(auth, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("session token is not signed: %s", auth)
+	}
+
+	// The body is part of the signature.
+	other, _ := http.NewRequest(http.MethodPost, "https://ssm.eu-west-1.amazonaws.com/", nil)
+	signAWSRequest(other, []byte(`{"Path":"/x"}`), creds, "eu-west-1", "ssm", exampleTime)
+	if req.Header.Get("Authorization") == other.Header.Get("Authorization") {
+		t.Error("requests with different bodies have the same signature")
+	}
+}
+
+func TestAWSSettings(t *testing.T) {
+	dir := t.TempDir()
+	credsFile := filepath.Join(dir, "credentials")
+	configFile := filepath.Join(dir, "config")
+	os.WriteFile(credsFile, []byte(`
+[default]
+aws_access_key_id = DEFAULTKEY
+aws_secret_access_key = default-secret
+
+[work]
+aws_access_key_id=WORKKEY
+aws_secret_access_key=work-secret
+aws_session_token=work-token
+`), 0600)
+	os.WriteFile(configFile, []byte(`
+[default]
+region = us-east-1
+
+; comment
+[profile work]
+region = eu-west-1
+
+[profile sso]
+sso_start_url = https://example.awsapps.com/start
+`), 0600)
+
+	tests := []struct {
+		name      string
+		env       map[string]string
+		profile   string
+		region    string
+		wantKey   string
+		wantToken string
+		wantReg   string
+		wantErr   string
+	}{
+		{name: "default profile", wantKey: "DEFAULTKEY", wantReg: "us-east-1"},
+		{name: "AWS_PROFILE", env: map[string]string{"AWS_PROFILE": "work"}, wantKey: "WORKKEY", wantToken: "work-token", wantReg: "eu-west-1"},
+		{name: "explicit profile and region", profile: "work", region: "ap-south-1", wantKey: "WORKKEY", wantToken: "work-token", wantReg: "ap-south-1"},
+		{
+			name:    "environment credentials",
+			env:     map[string]string{"AWS_ACCESS_KEY_ID": "ENVKEY", "AWS_SECRET_ACCESS_KEY": "env-secret", "AWS_REGION": "us-west-2"},
+			wantKey: "ENVKEY", wantReg: "us-west-2",
+		},
+		{
+			name:    "explicit profile beats environment credentials",
+			env:     map[string]string{"AWS_ACCESS_KEY_ID": "ENVKEY", "AWS_SECRET_ACCESS_KEY": "env-secret"},
+			profile: "work", wantKey: "WORKKEY", wantToken: "work-token", wantReg: "eu-west-1",
+		},
+		{name: "AWS_DEFAULT_REGION", env: map[string]string{"AWS_DEFAULT_REGION": "sa-east-1"}, wantKey: "DEFAULTKEY", wantReg: "sa-east-1"},
+		{name: "no region", env: map[string]string{"AWS_PROFILE": "nope"}, wantErr: "no AWS region"},
+		{name: "no static credentials", profile: "sso", region: "us-east-1", wantErr: `no AWS credentials for profile "sso"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"AWS_SHARED_CREDENTIALS_FILE": credsFile, "AWS_CONFIG_FILE": configFile}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			creds, region, err := awsSettings(func(k string) string { return env[k] }, tt.profile, tt.region)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if creds.AccessKeyID != tt.wantKey || creds.SessionToken != tt.wantToken || region != tt.wantReg {
+				t.Errorf("got key %s, token %q, region %s; want %s, %q, %s", creds.AccessKeyID, creds.SessionToken, region, tt.wantKey, tt.wantToken, tt.wantReg)
+			}
+		})
+	}
+}
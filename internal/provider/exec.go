@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Exec runs the reference body as a shell command in the project directory
+// and uses its standard output, without the trailing newline, as the value:
+//
+//	ref+exec://pass show db/prod
+//
+// The command's stderr is passed through, so prompts and errors are visible.
+type Exec struct{}
+
+func (Exec) Resolve(ctx context.Context, body, dir string) (string, error) {
+	if strings.TrimSpace(body) == "" {
+		return "", fmt.Errorf("empty command")
+	}
+
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/c", body)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", body)
+	}
+	c.Dir = dir
+	c.Stdin = os.Stdin
+	c.Stderr = os.Stderr
+	// Don't wait forever on children of the shell that keep stdout open.
+	c.WaitDelay = time.Second
+
+	var out bytes.Buffer
+	c.Stdout = &out
+	if err := c.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return "", fmt.Errorf("command exited with status %d", exitErr.ExitCode())
+		}
+		return "", err
+	}
+	return trimNewline(out.String()), nil
+}
+
+// trimNewline removes a single trailing newline (\n or \r\n).
+func trimNewline(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
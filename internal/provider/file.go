@@ -0,0 +1,26 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// File reads the value from a file, without its trailing newline. Relative
+// paths are resolved against the project directory:
+//
+//	ref+file:///run/secrets/db_password
+//	ref+file://secrets/token
+type File struct{}
+
+func (File) Resolve(_ context.Context, body, dir string) (string, error) {
+	path := body
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return trimNewline(string(data)), nil
+}
@@ -0,0 +1,172 @@
+// Package provider resolves secret references in env values.
+//
+// A reference has the form
+//
+//	ref+<scheme>://<body>[#/<key>[/<key>...]]
+//
+// The provider registered for scheme turns body into a value. When a
+// fragment is given, the value is parsed as a JSON object and the key path
+// is selected from it, so one command can supply several variables:
+//
+//	DB_USER: ref+exec://vault-cli get db#/username
+//	DB_PASS: ref+exec://vault-cli get db#/password
+//
+// It also reads whole secrets from secret stores, for env sources such as
+// vault: that load several variables at once.
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix marks a value as a reference.
+const Prefix = "ref+"
+
+// DefaultTimeout bounds how long a single reference may take to resolve.
+// It can be changed with $MENV_REF_TIMEOUT (e.g. "30s").
+const DefaultTimeout = 10 * time.Second
+
+// Provider resolves the body of a reference. dir is the project directory,
+// for providers that deal with relative paths.
+type Provider interface {
+	Resolve(ctx context.Context, body, dir string) (string, error)
+}
+
+var providers = map[string]Provider{
+	"exec": Exec{},
+	"file": File{},
+}
+
+// Register makes p available under scheme, replacing any existing provider.
+func Register(scheme string, p Provider) {
+	providers[scheme] = p
+}
+
+// IsRef reports whether value is a reference.
+func IsRef(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Resolver resolves references and secret sources such as Vault, and caches
+// the results until Reset, so a command referenced by several variables
+// runs only once.
+type Resolver struct {
+	// Timeout bounds each resolution; $MENV_REF_TIMEOUT or DefaultTimeout when zero.
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	cache   map[string]string
+	secrets map[string]map[string]string
+}
+
+// Default is the resolver used when loading envs.
+var Default = &Resolver{}
+
+// Resolve returns the value value refers to, or value itself if it is not
+// a reference.
+func (r *Resolver) Resolve(value, dir string) (string, error) {
+	if !IsRef(value) {
+		return value, nil
+	}
+
+	scheme, rest, ok := strings.Cut(strings.TrimPrefix(value, Prefix), "://")
+	if !ok {
+		return "", fmt.Errorf("malformed reference %q (expected ref+<scheme>://...)", value)
+	}
+	p, ok := providers[scheme]
+	if !ok {
+		return "", fmt.Errorf("unknown reference provider %q", scheme)
+	}
+
+	body, fragment := rest, ""
+	if i := strings.LastIndex(rest, "#/"); i >= 0 {
+		body, fragment = rest[:i], rest[i+2:]
+	}
+
+	raw, err := r.resolve(p, scheme, body, dir)
+	if err != nil {
+		return "", err
+	}
+	if fragment == "" {
+		return raw, nil
+	}
+	return selectKey(raw, fragment)
+}
+
+func (r *Resolver) resolve(p Provider, scheme, body, dir string) (string, error) {
+	key := scheme + "\x00" + dir + "\x00" + body
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if v, ok := r.cache[key]; ok {
+		return v, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout())
+	defer cancel()
+	v, err := p.Resolve(ctx, body, dir)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s reference %q timed out after %s", scheme, body, r.timeout())
+		}
+		return "", fmt.Errorf("%s reference %q: %w", scheme, body, err)
+	}
+
+	if r.cache == nil {
+		r.cache = make(map[string]string)
+	}
+	r.cache[key] = v
+	return v, nil
+}
+
+// Reset forgets every cached result, so that the next lookups fetch the
+// current values.
+func (r *Resolver) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cache, r.secrets = nil, nil
+}
+
+func (r *Resolver) timeout() time.Duration {
+	if r.Timeout > 0 {
+		return r.Timeout
+	}
+	if d, err := time.ParseDuration(os.Getenv("MENV_REF_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return DefaultTimeout
+}
+
+// selectKey parses raw as a JSON object and returns the value at the
+// slash-separated key path. Non-string values are returned as JSON.
+func selectKey(raw, path string) (string, error) {
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return "", fmt.Errorf("cannot select #/%s: output is not JSON: %w", path, err)
+	}
+	for _, k := range strings.Split(path, "/") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("cannot select #/%s: %q is not inside an object", path, k)
+		}
+		if v, ok = obj[k]; !ok {
+			return "", fmt.Errorf("cannot select #/%s: key %q not found", path, k)
+		}
+	}
+
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case nil:
+		return "", nil
+	default:
+		b, err := json.Marshal(t)
+		return string(b), err
+	}
+}
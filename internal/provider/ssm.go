@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// SSMParameters identifies a tree of parameters in AWS Systems Manager
+// Parameter Store.
+type SSMParameters struct {
+	// Path is the hierarchy prefix, e.g. /myapp/prod.
+	Path string
+	// Region and Profile override $AWS_REGION and $AWS_PROFILE.
+	Region  string
+	Profile string
+	// Endpoint overrides the service URL, e.g. http://localhost:4566 for
+	// LocalStack; $AWS_ENDPOINT_URL_SSM or $AWS_ENDPOINT_URL when empty.
+	Endpoint string
+	// Getenv reads AWS settings such as AWS_PROFILE; os.Getenv when nil.
+	Getenv func(string) string
+}
+
+func (p SSMParameters) path() string {
+	if p.Path == "/" {
+		return p.Path
+	}
+	return "/" + strings.Trim(p.Path, "/")
+}
+
+// SSM returns every parameter under p.Path, recursively, keyed by its name
+// relative to the path (e.g. "db/password" for /myapp/prod/db/password).
+// SecureString parameters are decrypted. Results are cached until Reset.
+func (r *Resolver) SSM(p SSMParameters) (map[string]string, error) {
+	getenv := p.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	creds, region, err := awsSettings(getenv, p.Profile, p.Region)
+	if err != nil {
+		return nil, fmt.Errorf("ssm %s: %w", p.path(), err)
+	}
+
+	endpoint := p.Endpoint
+	if endpoint == "" {
+		endpoint = getenv("AWS_ENDPOINT_URL_SSM")
+	}
+	if endpoint == "" {
+		endpoint = getenv("AWS_ENDPOINT_URL")
+	}
+	if endpoint == "" {
+		endpoint = "https://ssm." + region + ".amazonaws.com"
+	}
+
+	key := "ssm\x00" + endpoint + "\x00" + region + "\x00" + creds.AccessKeyID + "\x00" + p.path()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if params, ok := r.secrets[key]; ok {
+		return params, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout())
+	defer cancel()
+	params, err := getParametersByPath(ctx, endpoint, region, creds, p.path())
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("ssm %s: timed out after %s", p.path(), r.timeout())
+		}
+		return nil, fmt.Errorf("ssm %s: %w", p.path(), err)
+	}
+
+	if r.secrets == nil {
+		r.secrets = make(map[string]map[string]string)
+	}
+	r.secrets[key] = params
+	return params, nil
+}
+
+// getParametersByPath calls the GetParametersByPath API, following
+// pagination until every parameter has been read.
+func getParametersByPath(ctx context.Context, endpoint, region string, creds awsCredentials, path string) (map[string]string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", endpoint)
+	}
+
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	params := make(map[string]string)
+	next := ""
+	for {
+		reqBody := map[string]any{
+			"Path":           path,
+			"Recursive":      true,
+			"WithDecryption": true,
+			"MaxResults":     10,
+		}
+		if next != "" {
+			reqBody["NextToken"] = next
+		}
+		body, err := json.Marshal(reqBody)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "AmazonSSM.GetParametersByPath")
+		signAWSRequest(req, body, creds, region, "ssm", time.Now())
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, awsError(resp.Status, respBody)
+		}
+
+		var out struct {
+			Parameters []struct {
+				Name  string `json:"Name"`
+				Value string `json:"Value"`
+			} `json:"Parameters"`
+			NextToken string `json:"NextToken"`
+		}
+		if err := json.Unmarshal(respBody, &out); err != nil {
+			return nil, fmt.Errorf("malformed response: %w", err)
+		}
+		for _, p := range out.Parameters {
+			params[strings.TrimPrefix(p.Name, prefix)] = p.Value
+		}
+
+		if out.NextToken == "" {
+			return params, nil
+		}
+		next = out.NextToken
+	}
+}
+
+// awsError turns an AWS JSON error response into an error.
+func awsError(status string, body []byte) error {
+	var e struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+	}
+	if json.Unmarshal(body, &e) != nil || e.Type == "" {
+		return fmt.Errorf("%s", status)
+	}
+	msg := e.Message
+	if msg == "" {
+		msg = e.MessageUpper
+	}
+	// Types look like "com.amazonaws.ssm#AccessDeniedException".
+	typ := e.Type[strings.LastIndex(e.Type, "#")+1:]
+	if msg == "" {
+		return fmt.Errorf("%s", typ)
+	}
+	return fmt.Errorf("%s: %s", typ, msg)
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeSSM is a stand-in for the GetParametersByPath API. It returns params
+// in pages of pageSize, using the page number as the NextToken.
+type fakeSSM struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+func newFakeSSM(t *testing.T, params [][2]string, pageSize int) *fakeSSM {
+	t.Helper()
+	f := &fakeSSM{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.requests.Add(1)
+		if got := r.Header.Get("X-Amz-Target"); got != "AmazonSSM.GetParametersByPath" {
+			t.Errorf("X-Amz-Target = %q", got)
+		}
+		if auth := r.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+			!strings.Contains(auth, "/eu-west-1/ssm/aws4_request,") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"InvalidSignatureException","message":"bad signature"}`))
+			return
+		}
+
+		var in struct {
+			Path           string
+			Recursive      bool
+			WithDecryption bool
+			MaxResults     int
+			NextToken      string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if in.Path != "/app/prod" || !in.Recursive || !in.WithDecryption || in.MaxResults != 10 {
+			t.Errorf("unexpected request %+v", in)
+		}
+
+		page := 0
+		if in.NextToken != "" {
+			fmt.Sscanf(in.NextToken, "page-%d", &page)
+		}
+		type param struct{ Name, Value, Type string }
+		var out struct {
+			Parameters []param
+			NextToken  string `json:",omitempty"`
+		}
+		out.Parameters = []param{}
+		for i := page * pageSize; i < len(params) && i < (page+1)*pageSize; i++ {
+			out.Parameters = append(out.Parameters, param{params[i][0], params[i][1], "SecureString"})
+		}
+		if (page+1)*pageSize < len(params) {
+			out.NextToken = fmt.Sprintf("page-%d", page+1)
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// ssmEnv returns a Getenv with static credentials that ignores the shared
+// AWS files.
+func ssmEnv(extra map[string]string) func(string) string {
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":           "AKIDEXAMPLE",
+		"AWS_SECRET_ACCESS_KEY":       "secret",
+		"AWS_REGION":                  "eu-west-1",
+		"AWS_SHARED_CREDENTIALS_FILE": "/nonexistent/credentials",
+		"AWS_CONFIG_FILE":             "/nonexistent/config",
+	}
+	for k, v := range extra {
+		env[k] = v
+	}
+	return func(k string) string { return env[k] }
+}
+
+func TestSSMPagination(t *testing.T) {
+	var params [][2]string
+	want := map[string]string{}
+	for i := 0; i < 25; i++ {
+		name := fmt.Sprintf("key_%02d", i)
+		params = append(params, [2]string{"/app/prod/" + name, "v" + name})
+		want[name] = "v" + name
+	}
+	params = append(params, [2]string{"/app/prod/db/password", "hunter2"})
+	want["db/password"] = "hunter2"
+
+	f := newFakeSSM(t, params, 10)
+	got, err := (&Resolver{}).SSM(SSMParameters{Path: "app/prod/", Endpoint: f.URL, Getenv: ssmEnv(nil)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := f.requests.Load(); n != 3 {
+		t.Errorf("server got %d requests, want 3 pages", n)
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d parameters, want %d", len(got), len(want))
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestSSMEndpointAndCaching(t *testing.T) {
+	f := newFakeSSM(t, [][2]string{{"/app/prod/k", "v"}}, 10)
+
+	r := &Resolver{}
+	for _, env := range []map[string]string{
+		{"AWS_ENDPOINT_URL": f.URL},
+		{"AWS_ENDPOINT_URL_SSM": f.URL, "AWS_ENDPOINT_URL": "http://127.0.0.1:1"},
+	} {
+		got, err := r.SSM(SSMParameters{Path: "/app/prod", Getenv: ssmEnv(env)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got["k"] != "v" {
+			t.Errorf("k = %q, want v", got["k"])
+		}
+	}
+	if n := f.requests.Load(); n != 1 {
+		t.Errorf("server got %d requests, want 1", n)
+	}
+}
+
+func TestSSMErrors(t *testing.T) {
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"com.amazonaws.ssm#AccessDeniedException","Message":"not authorized to perform ssm:GetParametersByPath"}`))
+	}))
+	defer denied.Close()
+	typeOnly := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ThrottlingException"}`))
+	}))
+	defer typeOnly.Close()
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer plain.Close()
+	malformed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer malformed.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		want     string
+	}{
+		{"typed error", denied.URL, "ssm /app/prod: AccessDeniedException: not authorized to perform ssm:GetParametersByPath"},
+		{"type only", typeOnly.URL, "ssm /app/prod: ThrottlingException"},
+		{"status only", plain.URL, "ssm /app/prod: 503 Service Unavailable"},
+		{"malformed", malformed.URL, "malformed response"},
+		{"invalid endpoint", "localhost", `invalid endpoint "localhost"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := (&Resolver{}).SSM(SSMParameters{Path: "/app/prod", Endpoint: tt.endpoint, Getenv: ssmEnv(nil)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
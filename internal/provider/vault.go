@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// VaultSecret identifies a secret in a HashiCorp Vault KV v2 engine.
+type VaultSecret struct {
+	// Address is the server URL; $VAULT_ADDR when empty.
+	Address string
+	// Mount is the engine's mount path; "secret" when empty.
+	Mount string
+	Path  string
+	// TokenFile is read when $VAULT_TOKEN is not set; ~/.vault-token when empty.
+	TokenFile string
+}
+
+func (s VaultSecret) address() string {
+	if s.Address != "" {
+		return s.Address
+	}
+	return os.Getenv("VAULT_ADDR")
+}
+
+func (s VaultSecret) mount() string {
+	if m := strings.Trim(s.Mount, "/"); m != "" {
+		return m
+	}
+	return "secret"
+}
+
+// String returns the secret's mount and path, e.g. "secret/my-api/prod".
+func (s VaultSecret) String() string {
+	return s.mount() + "/" + strings.Trim(s.Path, "/")
+}
+
+// token returns $VAULT_TOKEN, or the contents of the token file.
+func (s VaultSecret) token() (string, error) {
+	if t := os.Getenv("VAULT_TOKEN"); t != "" {
+		return t, nil
+	}
+	path := s.TokenFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("no Vault token: set VAULT_TOKEN")
+		}
+		path = filepath.Join(home, ".vault-token")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("no Vault token: set VAULT_TOKEN or log in with 'vault login' (%s not found)", path)
+		}
+		return "", fmt.Errorf("failed to read Vault token file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Vault reads the latest version of a KV v2 secret and returns its fields.
+// Non-string fields are returned as JSON. Results are cached until Reset.
+func (r *Resolver) Vault(s VaultSecret) (map[string]string, error) {
+	addr := s.address()
+	if addr == "" {
+		return nil, fmt.Errorf("vault %s: no address (set address or VAULT_ADDR)", s)
+	}
+	key := "vault\x00" + addr + "\x00" + s.String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if fields, ok := r.secrets[key]; ok {
+		return fields, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout())
+	defer cancel()
+	fields, err := readVaultKV(ctx, addr, s)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("vault %s: timed out after %s", s, r.timeout())
+		}
+		return nil, fmt.Errorf("vault %s: %w", s, err)
+	}
+
+	if r.secrets == nil {
+		r.secrets = make(map[string]map[string]string)
+	}
+	r.secrets[key] = fields
+	return fields, nil
+}
+
+func readVaultKV(ctx context.Context, addr string, s VaultSecret) (map[string]string, error) {
+	token, err := s.token()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(strings.TrimRight(addr, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	u = u.JoinPath("v1", s.mount(), "data", strings.Trim(s.Path, "/"))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(body, &e) == nil && len(e.Errors) > 0 {
+			return nil, fmt.Errorf("%s: %s", resp.Status, strings.Join(e.Errors, "; "))
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("secret not found")
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	var out struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("malformed response: %w", err)
+	}
+	if out.Data.Data == nil {
+		return nil, fmt.Errorf("secret has no data (was it deleted?)")
+	}
+
+	fields := make(map[string]string, len(out.Data.Data))
+	for k, v := range out.Data.Data {
+		switch t := v.(type) {
+		case string:
+			fields[k] = t
+		case nil:
+			fields[k] = ""
+		default:
+			b, err := json.Marshal(t)
+			if err != nil {
+				return nil, err
+			}
+			fields[k] = string(b)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,195 @@
+package provider
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeVault is a stand-in for the KV v2 read endpoint. It serves the
+// secrets in kv, keyed by "<mount>/<path>", as raw JSON "data" objects.
+type fakeVault struct {
+	*httptest.Server
+	kv       map[string]string
+	requests atomic.Int32
+	// lastToken and lastNamespace record the headers of the last request.
+	lastToken     string
+	lastNamespace string
+}
+
+func newFakeVault(t *testing.T, kv map[string]string) *fakeVault {
+	t.Helper()
+	f := &fakeVault{kv: kv}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.requests.Add(1)
+		f.lastToken = r.Header.Get("X-Vault-Token")
+		f.lastNamespace = r.Header.Get("X-Vault-Namespace")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		mount, path, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/"), "/data/")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		switch data, ok := f.kv[mount+"/"+path]; {
+		case f.lastToken != "test-token":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+		case !ok:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		default:
+			w.Write([]byte(`{"request_id":"1","data":{"data":` + data + `,"metadata":{"version":3}}}`))
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func TestVault(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "test-token")
+	t.Setenv("VAULT_NAMESPACE", "team-a")
+	f := newFakeVault(t, map[string]string{
+		"secret/my-api/prod": `{"db_password":"hunter2","port":5432,"enabled":true,"nested":{"a":[1,2]},"empty":null}`,
+		"kv/other":           `{"token":"abc"}`,
+	})
+
+	r := &Resolver{}
+	fields, err := r.Vault(VaultSecret{Address: f.URL, Path: "/my-api/prod/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"db_password": "hunter2",
+		"port":        "5432",
+		"enabled":     "true",
+		"nested":      `{"a":[1,2]}`,
+		"empty":       "",
+	}
+	if len(fields) != len(want) {
+		t.Errorf("got fields %v, want %v", fields, want)
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("%s = %q, want %q", k, fields[k], v)
+		}
+	}
+	if f.lastNamespace != "team-a" {
+		t.Errorf("namespace header = %q, want team-a", f.lastNamespace)
+	}
+
+	// Other mounts, and VAULT_ADDR when no address is configured.
+	t.Setenv("VAULT_ADDR", f.URL+"/")
+	if fields, err := r.Vault(VaultSecret{Mount: "/kv/", Path: "other"}); err != nil || fields["token"] != "abc" {
+		t.Errorf("kv/other = %v, %v; want token=abc", fields, err)
+	}
+}
+
+func TestVaultCaching(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "test-token")
+	f := newFakeVault(t, map[string]string{"secret/app": `{"k":"v"}`})
+
+	r := &Resolver{}
+	for i := 0; i < 3; i++ {
+		if _, err := r.Vault(VaultSecret{Address: f.URL, Path: "app"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := f.requests.Load(); n != 1 {
+		t.Errorf("server got %d requests, want 1", n)
+	}
+
+	// Errors are not cached.
+	for i := 0; i < 2; i++ {
+		if _, err := r.Vault(VaultSecret{Address: f.URL, Path: "missing"}); err == nil {
+			t.Fatal("expected an error for a missing secret")
+		}
+	}
+	if n := f.requests.Load(); n != 3 {
+		t.Errorf("server got %d requests, want 3", n)
+	}
+
+	// Reset drops the cache.
+	r.Reset()
+	if _, err := r.Vault(VaultSecret{Address: f.URL, Path: "app"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := f.requests.Load(); n != 4 {
+		t.Errorf("server got %d requests after Reset, want 4", n)
+	}
+}
+
+func TestVaultErrors(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "test-token")
+	f := newFakeVault(t, map[string]string{
+		"secret/deleted": `null`,
+	})
+	errorBody := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"errors":["internal error","storage unavailable"]}`))
+	}))
+	defer errorBody.Close()
+	plainError := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("<html>bad gateway</html>"))
+	}))
+	defer plainError.Close()
+	malformed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not json"))
+	}))
+	defer malformed.Close()
+
+	tests := []struct {
+		name   string
+		secret VaultSecret
+		token  string
+		want   string
+	}{
+		{"not found", VaultSecret{Address: f.URL, Path: "missing"}, "test-token", "vault secret/missing: secret not found"},
+		{"errors field", VaultSecret{Address: f.URL, Path: "missing"}, "wrong-token", "403 Forbidden: permission denied"},
+		{"several errors", VaultSecret{Address: errorBody.URL, Path: "x"}, "test-token", "500 Internal Server Error: internal error; storage unavailable"},
+		{"status only", VaultSecret{Address: plainError.URL, Path: "x"}, "test-token", "vault secret/x: 502 Bad Gateway"},
+		{"deleted", VaultSecret{Address: f.URL, Path: "deleted"}, "test-token", "secret has no data"},
+		{"malformed", VaultSecret{Address: malformed.URL, Path: "x"}, "test-token", "malformed response"},
+		{"no address", VaultSecret{Path: "x"}, "test-token", "no address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VAULT_TOKEN", tt.token)
+			t.Setenv("VAULT_ADDR", "")
+			_, err := (&Resolver{}).Vault(tt.secret)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultTokenFile(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "")
+	f := newFakeVault(t, map[string]string{"secret/app": `{"k":"v"}`})
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("test-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Resolver{}).Vault(VaultSecret{Address: f.URL, Path: "app", TokenFile: tokenFile}); err != nil {
+		t.Fatal(err)
+	}
+	if f.lastToken != "test-token" {
+		t.Errorf("token = %q, want it read from the token file", f.lastToken)
+	}
+
+	_, err := (&Resolver{}).Vault(VaultSecret{Address: f.URL, Path: "app", TokenFile: tokenFile + ".missing"})
+	if err == nil || !strings.Contains(err.Error(), "no Vault token") {
+		t.Errorf("error = %v, want a missing token error", err)
+	}
+}
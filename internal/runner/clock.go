@@ -0,0 +1,26 @@
+package runner
+
+import "time"
+
+// clock is the source of time for restarts and shutdowns, so that tests can
+// control it.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) timer
+}
+
+// timer is a pending call started by clock.AfterFunc.
+type timer interface {
+	// Stop cancels the call, reporting false if it has already been made.
+	Stop() bool
+}
+
+// realClock is the clock of the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) timer {
+	return time.AfterFunc(d, f)
+}
@@ -0,0 +1,302 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Shutdown policies, see Group.Shutdown.
+const (
+	// ShutdownOnExit stops the other processes when any process exits.
+	ShutdownOnExit = "exit"
+	// ShutdownOnFailure stops the other processes when a process fails;
+	// processes that exit successfully are left to finish.
+	ShutdownOnFailure = "failure"
+	// ShutdownNever keeps the other processes running until they exit on
+	// their own or menv is interrupted.
+	ShutdownNever = "never"
+)
+
+// ShutdownPolicies lists the accepted values of Group.Shutdown.
+var ShutdownPolicies = []string{ShutdownOnExit, ShutdownOnFailure, ShutdownNever}
+
+// DefaultGracePeriod is how long processes get to exit after being asked
+// to stop, before they are killed.
+const DefaultGracePeriod = 10 * time.Second
+
+// prefixColors are cycled through to tell processes apart.
+var prefixColors = []color.Attribute{
+	color.FgCyan, color.FgMagenta, color.FgYellow, color.FgGreen, color.FgBlue, color.FgRed,
+}
+
+// Process is one command of a Group, run through the shell like Run.
+type Process struct {
+	Name    string
+	Command string
+	Dir     string
+	Env     []string
+	// Restart says whether the process is started again when it exits.
+	Restart Restart
+}
+
+// Group runs several processes at once, Procfile-style, writing their
+// output line by line with a timestamp and the name of the process.
+type Group struct {
+	Processes []Process
+	// Shutdown is one of ShutdownPolicies; ShutdownOnExit when empty.
+	Shutdown string
+	// GracePeriod is how long stopped processes get before they are
+	// killed; DefaultGracePeriod when zero.
+	GracePeriod time.Duration
+	// Output receives the output of all processes; os.Stdout when nil.
+	Output io.Writer
+
+	// clock times restarts and the grace period; realClock when nil.
+	clock clock
+}
+
+// member is a process of a Group. Its cmd is replaced on every restart;
+// timer is set while it waits to be restarted.
+type member struct {
+	Process
+	prefix    string
+	cmd       *exec.Cmd
+	stdout    *prefixWriter
+	stderr    *prefixWriter
+	restarter *restarter
+	started   time.Time
+	timer     timer
+	done      bool
+}
+
+// Run starts every process and waits until all of them have exited for
+// good. A process that exits is restarted if its restart policy says so;
+// otherwise, when the shutdown policy calls for it, or when menv receives
+// an interrupt, the remaining processes are sent SIGTERM (or the interrupt
+// itself) and, after the grace period, killed. A second interrupt kills
+// them at once. Run returns the error of the first process that failed on
+// its own, or nil if they all succeeded or were stopped.
+func (g *Group) Run() error {
+	if len(g.Processes) == 0 {
+		return fmt.Errorf("no processes to run")
+	}
+	policy := g.Shutdown
+	if policy == "" {
+		policy = ShutdownOnExit
+	}
+	grace := g.GracePeriod
+	if grace == 0 {
+		grace = DefaultGracePeriod
+	}
+	clk := g.clock
+	if clk == nil {
+		clk = realClock{}
+	}
+	out := &lineWriter{w: g.Output}
+	if out.w == nil {
+		out.w = os.Stdout
+	}
+
+	width := len("menv")
+	for _, p := range g.Processes {
+		width = max(width, len(p.Name))
+	}
+	menvPrefix := color.HiBlackString("%-*s |", width, "menv")
+
+	// Listen for interrupts before starting anything, so none is missed.
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	type exit struct {
+		m   *member
+		err error
+	}
+	exits := make(chan exit, len(g.Processes))
+	restarts := make(chan *member, len(g.Processes))
+	var members []*member
+
+	start := func(m *member) error {
+		c := shellCommand(m.Command)
+		c.Env, c.Dir = m.Env, m.Dir
+		c.Stdout, c.Stderr = m.stdout, m.stderr
+		// Don't wait for background processes of the shell holding on to
+		// its output once it has exited.
+		c.WaitDelay = time.Second
+		setProcessGroup(c)
+		if err := c.Start(); err != nil {
+			out.line(m.prefix, color.RedString("failed to start: %v", err))
+			return fmt.Errorf("%s: %w", m.Name, err)
+		}
+		m.cmd, m.started = c, clk.Now()
+		out.line(m.prefix, color.HiBlackString("started (pid %d): %s", c.Process.Pid, m.Command))
+		go func() { exits <- exit{m, c.Wait()} }()
+		return nil
+	}
+
+	// running counts the members that have not exited for good, including
+	// those waiting to be restarted.
+	running := 0
+	stopAll := func(sig os.Signal) {
+		for _, m := range members {
+			switch {
+			case m.done:
+			case m.timer != nil:
+				// Cancel a pending restart. If the timer has already
+				// fired, the member is finished when it is received.
+				if m.timer.Stop() {
+					m.timer, m.done = nil, true
+					running--
+				}
+			default:
+				signalProcess(m.cmd, sig)
+			}
+		}
+	}
+
+	for i, p := range g.Processes {
+		name := fmt.Sprintf("%-*s |", width, p.Name)
+		m := &member{
+			Process:   p,
+			prefix:    color.New(prefixColors[i%len(prefixColors)]).Sprint(name),
+			restarter: newRestarter(p.Restart),
+		}
+		m.stdout = &prefixWriter{out: out, prefix: m.prefix}
+		m.stderr = &prefixWriter{out: out, prefix: m.prefix}
+		if err := start(m); err != nil {
+			stopAll(os.Kill)
+			for range members {
+				<-exits
+			}
+			return err
+		}
+		members = append(members, m)
+		running++
+	}
+
+	var failure error
+	stopping := false
+	var killTimer <-chan time.Time
+	stop := func(sig os.Signal) {
+		stopping = true
+		stopAll(sig)
+		killTimer = clk.After(grace)
+	}
+	// finish records that m has exited for good with err, applying the
+	// shutdown policy.
+	finish := func(m *member, err error) {
+		m.done = true
+		running--
+		if stopping {
+			return
+		}
+		if err != nil && failure == nil {
+			failure = fmt.Errorf("%s: %w", m.Name, err)
+		}
+		if running > 0 && (policy == ShutdownOnExit || (policy == ShutdownOnFailure && err != nil)) {
+			out.line(menvPrefix, fmt.Sprintf("%s exited, stopping the other processes", m.Name))
+			stop(syscall.SIGTERM)
+		}
+	}
+
+	for running > 0 {
+		select {
+		case e := <-exits:
+			m := e.m
+			m.stdout.flush()
+			m.stderr.flush()
+			if stopping {
+				out.line(m.prefix, color.HiBlackString("exited: %s", exitStatus(e.err)))
+				finish(m, e.err)
+				continue
+			}
+			delay, reason, ok := m.restarter.next(e.err, clk.Now().Sub(m.started))
+			switch {
+			case ok:
+				out.line(m.prefix, color.YellowString("exited: %s; restarting in %s (%s)", exitStatus(e.err), delay, m.restarter.count()))
+				m.timer = clk.AfterFunc(delay, func() { restarts <- m })
+				continue
+			case reason != "":
+				out.line(m.prefix, color.RedString("exited: %s; not restarting (%s)", exitStatus(e.err), reason))
+			case e.err != nil:
+				out.line(m.prefix, color.RedString("exited: %s", exitStatus(e.err)))
+			default:
+				out.line(m.prefix, color.HiBlackString("exited: %s", exitStatus(e.err)))
+			}
+			finish(m, e.err)
+		case m := <-restarts:
+			m.timer = nil
+			if stopping {
+				m.done = true
+				running--
+				continue
+			}
+			if err := start(m); err != nil {
+				finish(m, err)
+			}
+		case sig := <-sigs:
+			if stopping {
+				stopAll(os.Kill)
+				continue
+			}
+			out.line(menvPrefix, fmt.Sprintf("%v received, stopping all processes (again to kill)", sig))
+			stop(sig)
+		case <-killTimer:
+			out.line(menvPrefix, fmt.Sprintf("grace period of %s is over, killing the remaining processes", grace))
+			stopAll(os.Kill)
+		}
+	}
+	return failure
+}
+
+// lineWriter writes whole lines from several processes to w, each with a
+// timestamp and a prefix.
+type lineWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lineWriter) line(prefix, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "%s %s %s\n", color.HiBlackString(time.Now().Format("15:04:05")), prefix, text)
+}
+
+// prefixWriter collects the output of one stream of a process and passes it
+// on to a lineWriter a line at a time.
+type prefixWriter struct {
+	out    *lineWriter
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.out.line(w.prefix, strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush writes out a final line that did not end in a newline.
+func (w *prefixWriter) flush() {
+	if len(w.buf) > 0 {
+		w.out.line(w.prefix, string(w.buf))
+		w.buf = nil
+	}
+}
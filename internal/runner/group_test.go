@@ -0,0 +1,281 @@
+//go:build !windows
+
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// fakeClock is a clock whose timers fire only when the test says so. Every
+// timer it starts is sent on timers; firing it moves the time forward.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers chan *fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	d       time.Duration
+	f       func()
+	ch      chan time.Time
+	stopped bool
+	fired   bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), timers: make(chan *fakeTimer, 16)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	t := &fakeTimer{clock: c, d: d, ch: make(chan time.Time, 1)}
+	c.timers <- t
+	return t.ch
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) timer {
+	t := &fakeTimer{clock: c, d: d, f: f}
+	c.timers <- t
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.fired || t.stopped {
+		return false
+	}
+	t.stopped = true
+	return true
+}
+
+// fire advances the clock by the timer's duration and fires it, unless it
+// was stopped.
+func (t *fakeTimer) fire() {
+	c := t.clock
+	c.mu.Lock()
+	if t.stopped || t.fired {
+		c.mu.Unlock()
+		return
+	}
+	t.fired = true
+	c.now = c.now.Add(t.d)
+	now := c.now
+	c.mu.Unlock()
+	if t.f != nil {
+		go t.f()
+	} else {
+		t.ch <- now
+	}
+}
+
+// runGroup runs g with a fake clock. onTimer is called with every timer Run
+// starts, and may fire it. It returns the error of Run and its output.
+func runGroup(t *testing.T, g *Group, onTimer func(t *fakeTimer)) (error, string) {
+	t.Helper()
+	color.NoColor = true
+	clock := newFakeClock()
+	var out bytes.Buffer
+	g.clock, g.Output = clock, &out
+
+	done := make(chan error, 1)
+	go func() { done <- g.Run() }()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			return err, out.String()
+		case tm := <-clock.timers:
+			onTimer(tm)
+		case <-timeout:
+			t.Fatalf("Run did not return; output so far:\n%s", out.String())
+		}
+	}
+}
+
+// fireAll fires every timer and records its duration.
+func fireAll(delays *[]time.Duration) func(*fakeTimer) {
+	return func(t *fakeTimer) {
+		*delays = append(*delays, t.d)
+		t.fire()
+	}
+}
+
+func wantLines(t *testing.T, out string, want ...string) {
+	t.Helper()
+	for _, w := range want {
+		if !strings.Contains(out, w) {
+			t.Errorf("output does not contain %q:\n%s", w, out)
+		}
+	}
+}
+
+func TestGroupRestartBackoff(t *testing.T) {
+	var delays []time.Duration
+	g := &Group{Processes: []Process{{
+		Name:    "crash",
+		Command: "echo run; exit 3",
+		Restart: Restart{Policy: RestartOnFailure, MaxRetries: 3, Backoff: time.Second, MaxBackoff: 3 * time.Second},
+	}}}
+	err, out := runGroup(t, g, fireAll(&delays))
+
+	if err == nil || err.Error() != "crash: exit status 3" {
+		t.Errorf("error = %v", err)
+	}
+	want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}
+	if len(delays) != len(want) || delays[0] != want[0] || delays[1] != want[1] || delays[2] != want[2] {
+		t.Errorf("delays = %v, want %v", delays, want)
+	}
+	if n := strings.Count(out, "crash | run"); n != 4 {
+		t.Errorf("ran %d times, want 4:\n%s", n, out)
+	}
+	wantLines(t, out,
+		"exited: exit status 3; restarting in 1s (restart 1 of 3)",
+		"exited: exit status 3; restarting in 3s (restart 3 of 3)",
+		"exited: exit status 3; not restarting (gave up after 3 restart(s))",
+	)
+}
+
+func TestGroupRestartFlapping(t *testing.T) {
+	var delays []time.Duration
+	g := &Group{Processes: []Process{{
+		Name:    "flap",
+		Command: "exit 0",
+		Restart: Restart{Policy: RestartAlways, Backoff: time.Second, MinUptime: 5 * time.Second, FlapLimit: 3},
+	}}}
+	// The clock only moves while a restart is pending, so every run has an
+	// uptime of zero.
+	err, out := runGroup(t, g, fireAll(&delays))
+
+	if err != nil {
+		t.Errorf("error = %v", err)
+	}
+	if len(delays) != 2 {
+		t.Errorf("delays = %v, want two restarts", delays)
+	}
+	wantLines(t, out, "not restarting (flapping: exited 3 times in a row within 5s of starting)")
+}
+
+func TestGroupShutdownPolicies(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		processes []Process
+		wantErr   string
+		want      []string
+		notWant   []string
+	}{
+		{
+			name:      "exit stops the others when one succeeds",
+			policy:    ShutdownOnExit,
+			processes: []Process{{Name: "once", Command: "exit 0"}, {Name: "server", Command: "sleep 30"}},
+			want:      []string{"once exited, stopping the other processes", "server | exited: signal: terminated"},
+		},
+		{
+			name:      "default is exit",
+			processes: []Process{{Name: "fail", Command: "exit 2"}, {Name: "server", Command: "sleep 30"}},
+			wantErr:   "fail: exit status 2",
+			want:      []string{"fail exited, stopping the other processes"},
+		},
+		{
+			name:      "failure stops the others when one fails",
+			policy:    ShutdownOnFailure,
+			processes: []Process{{Name: "fail", Command: "exit 2"}, {Name: "server", Command: "sleep 30"}},
+			wantErr:   "fail: exit status 2",
+			want:      []string{"fail exited, stopping the other processes", "server | exited: signal: terminated"},
+		},
+		{
+			name:      "failure leaves the others when one succeeds",
+			policy:    ShutdownOnFailure,
+			processes: []Process{{Name: "once", Command: "exit 0"}, {Name: "job", Command: "sleep 0.2; echo finished"}},
+			want:      []string{"job  | finished", "job  | exited: exit status 0"},
+			notWant:   []string{"stopping"},
+		},
+		{
+			name:      "never leaves the others when one fails",
+			policy:    ShutdownNever,
+			processes: []Process{{Name: "fail", Command: "exit 2"}, {Name: "job", Command: "sleep 0.2; echo finished"}},
+			wantErr:   "fail: exit status 2",
+			want:      []string{"job  | finished"},
+			notWant:   []string{"stopping"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The grace period never runs out here.
+			g := &Group{Processes: tt.processes, Shutdown: tt.policy}
+			err, out := runGroup(t, g, func(*fakeTimer) {})
+
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+			wantLines(t, out, tt.want...)
+			for _, w := range tt.notWant {
+				if strings.Contains(out, w) {
+					t.Errorf("output contains %q:\n%s", w, out)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupGracePeriod(t *testing.T) {
+	var delays []time.Duration
+	g := &Group{
+		Processes: []Process{
+			// The sleep gives stubborn time to set its trap.
+			{Name: "fail", Command: "sleep 0.2; exit 1"},
+			// Ignores SIGTERM, and so does the sleep it starts.
+			{Name: "stubborn", Command: "trap '' TERM; sleep 30"},
+		},
+		GracePeriod: 3 * time.Second,
+	}
+	err, out := runGroup(t, g, fireAll(&delays))
+
+	if err == nil || err.Error() != "fail: exit status 1" {
+		t.Errorf("error = %v", err)
+	}
+	if len(delays) != 1 || delays[0] != 3*time.Second {
+		t.Errorf("timers = %v, want the 3s grace period", delays)
+	}
+	wantLines(t, out, "grace period of 3s is over, killing the remaining processes", "stubborn | exited: signal: killed")
+}
+
+func TestGroupSignalCancelsPendingRestart(t *testing.T) {
+	g := &Group{Processes: []Process{
+		{Name: "again", Command: "exit 0", Restart: Restart{Policy: RestartAlways}},
+		{Name: "server", Command: "sleep 30"},
+	}}
+	var restart *fakeTimer
+	err, out := runGroup(t, g, func(tm *fakeTimer) {
+		if restart == nil {
+			// The restart of "again" is pending: interrupt menv.
+			restart = tm
+			syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+		}
+	})
+
+	if err != nil {
+		t.Errorf("error = %v", err)
+	}
+	if restart == nil || restart.Stop() {
+		t.Errorf("pending restart was not cancelled")
+	}
+	wantLines(t, out, "terminated received, stopping all processes", "server | exited: signal: terminated")
+	if strings.Count(out, "again  | started") != 1 {
+		t.Errorf("again was restarted:\n%s", out)
+	}
+}
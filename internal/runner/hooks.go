@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// ExitCodeVar is set for post_run and on_failure hooks to the exit code of
+// the command.
+const ExitCodeVar = "MENV_EXIT_CODE"
+
+// Hooks are shell commands run around a command, see Hooks.Around.
+type Hooks struct {
+	PreRun    []string
+	PostRun   []string
+	OnFailure []string
+	// Dir is the working directory of the hooks.
+	Dir string
+	// Notify, if set, is called with the event and the command before each
+	// hook runs.
+	Notify func(event, command string)
+}
+
+// Around runs the pre_run hooks, then main, then the on_failure hooks if
+// main failed and finally the post_run hooks. Every hook gets envVars;
+// post_run and on_failure hooks also get the exit code of main (see
+// ExitCode) in ExitCodeVar. A failing pre_run hook aborts the run. Failing
+// post_run and on_failure hooks do not stop the others; their errors are
+// returned if main itself succeeded.
+func (h Hooks) Around(envVars []string, main func() error) error {
+	run := func(event, command string, envVars []string) error {
+		if h.Notify != nil {
+			h.Notify(event, command)
+		}
+		if err := Run([]string{command}, envVars, h.Dir); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", event, command, err)
+		}
+		return nil
+	}
+
+	for _, c := range h.PreRun {
+		if err := run("pre_run", c, envVars); err != nil {
+			return err
+		}
+	}
+
+	mainErr := main()
+
+	postEnv := append(envVars[:len(envVars):len(envVars)], ExitCodeVar+"="+strconv.Itoa(ExitCode(mainErr)))
+	var hookErrs []error
+	if mainErr != nil {
+		for _, c := range h.OnFailure {
+			hookErrs = append(hookErrs, run("on_failure", c, postEnv))
+		}
+	}
+	for _, c := range h.PostRun {
+		hookErrs = append(hookErrs, run("post_run", c, postEnv))
+	}
+
+	if mainErr != nil {
+		return mainErr
+	}
+	return errors.Join(hookErrs...)
+}
+
+// ExitCode returns the exit code a shell would report for err, the result
+// of running a command: 0 for nil, 128+n for a command killed by signal n
+// and 1 if the command could not be started.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 1
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts c in a process group of its own, so that signals
+// reach every process the shell starts, and a Ctrl-C in the terminal goes
+// only to menv, which then decides how to stop the children.
+func setProcessGroup(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcess sends sig to the process group of c.
+func signalProcess(c *exec.Cmd, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return c.Process.Signal(sig)
+	}
+	return syscall.Kill(-c.Process.Pid, s)
+}
+
+// groupAlive reports whether a process of the process group of c is still
+// running.
+func groupAlive(c *exec.Cmd) bool {
+	return syscall.Kill(-c.Process.Pid, 0) == nil
+}
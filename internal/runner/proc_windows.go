@@ -0,0 +1,23 @@
+//go:build windows
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows, where the console already sends
+// Ctrl-C to every process attached to it.
+func setProcessGroup(c *exec.Cmd) {}
+
+// signalProcess kills c: Windows cannot deliver other signals to a process.
+func signalProcess(c *exec.Cmd, sig os.Signal) error {
+	return c.Process.Kill()
+}
+
+// groupAlive reports false: without process groups there is nothing to wait
+// for once c has exited.
+func groupAlive(c *exec.Cmd) bool {
+	return false
+}
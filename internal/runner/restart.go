@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+)
+
+// Restart policies, see Restart.Policy.
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// RestartPolicies lists the accepted values of Restart.Policy.
+var RestartPolicies = []string{RestartNever, RestartOnFailure, RestartAlways}
+
+// Defaults for the zero fields of Restart.
+const (
+	DefaultBackoff    = time.Second
+	DefaultMaxBackoff = time.Minute
+	DefaultMinUptime  = 5 * time.Second
+	DefaultFlapLimit  = 5
+)
+
+// Restart says when a command is started again after it exits.
+type Restart struct {
+	// Policy is one of RestartPolicies; RestartNever when empty.
+	Policy string
+	// MaxRetries limits the number of restarts; 0 means no limit.
+	MaxRetries int
+	// Backoff is the delay before the first restart. It doubles with every
+	// quick crash in a row, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// MinUptime is how long a command must run for its exit not to count as
+	// a quick crash. A run that lasts this long resets the backoff.
+	MinUptime time.Duration
+	// FlapLimit is the number of quick crashes in a row after which the
+	// command is considered to be flapping and no longer restarted.
+	FlapLimit int
+}
+
+// restarter applies a Restart to the runs of one command.
+type restarter struct {
+	Restart
+	restarts int
+	quick    int
+	delay    time.Duration
+}
+
+func newRestarter(r Restart) *restarter {
+	if r.Backoff <= 0 {
+		r.Backoff = DefaultBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = DefaultMaxBackoff
+	}
+	if r.MinUptime <= 0 {
+		r.MinUptime = DefaultMinUptime
+	}
+	if r.FlapLimit <= 0 {
+		r.FlapLimit = DefaultFlapLimit
+	}
+	return &restarter{Restart: r, delay: r.Backoff}
+}
+
+// next decides whether to restart a command that exited with err after
+// running for uptime, and after what delay. When it is not restarted
+// because of a limit, reason says which.
+func (r *restarter) next(err error, uptime time.Duration) (delay time.Duration, reason string, ok bool) {
+	switch r.Policy {
+	case RestartAlways:
+	case RestartOnFailure:
+		if err == nil {
+			return 0, "", false
+		}
+	default:
+		return 0, "", false
+	}
+
+	if uptime >= r.MinUptime {
+		r.quick = 0
+		r.delay = r.Backoff
+	} else {
+		r.quick++
+		if r.quick >= r.FlapLimit {
+			return 0, fmt.Sprintf("flapping: exited %d times in a row within %s of starting", r.quick, r.MinUptime), false
+		}
+	}
+	if r.MaxRetries > 0 && r.restarts >= r.MaxRetries {
+		return 0, fmt.Sprintf("gave up after %d restart(s)", r.restarts), false
+	}
+
+	r.restarts++
+	delay = r.delay
+	r.delay = min(r.delay*2, r.MaxBackoff)
+	return delay, "", true
+}
+
+// count describes the restart about to happen, e.g. "restart 2 of 5".
+func (r *restarter) count() string {
+	if r.MaxRetries > 0 {
+		return fmt.Sprintf("restart %d of %d", r.restarts, r.MaxRetries)
+	}
+	return fmt.Sprintf("restart %d", r.restarts)
+}
+
+// exitStatus describes how a command exited.
+func exitStatus(err error) string {
+	if err == nil {
+		return "exit status 0"
+	}
+	return err.Error()
+}
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errExit = errors.New("exit status 1")
+
+func TestRestartPolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		err    error
+		want   bool
+	}{
+		{"", nil, false},
+		{"", errExit, false},
+		{RestartNever, errExit, false},
+		{RestartOnFailure, nil, false},
+		{RestartOnFailure, errExit, true},
+		{RestartAlways, nil, true},
+		{RestartAlways, errExit, true},
+	}
+	for _, tt := range tests {
+		r := newRestarter(Restart{Policy: tt.policy})
+		if _, reason, ok := r.next(tt.err, time.Minute); ok != tt.want || reason != "" {
+			t.Errorf("%q after %v: ok = %v (reason %q), want %v", tt.policy, tt.err, ok, reason, tt.want)
+		}
+	}
+}
+
+func TestRestartDefaults(t *testing.T) {
+	r := newRestarter(Restart{Policy: RestartAlways})
+	if r.Backoff != DefaultBackoff || r.MaxBackoff != DefaultMaxBackoff || r.MinUptime != DefaultMinUptime || r.FlapLimit != DefaultFlapLimit {
+		t.Errorf("defaults = %+v", r.Restart)
+	}
+}
+
+// step is one exit of a command and what next should decide.
+type step struct {
+	uptime time.Duration
+	delay  time.Duration
+	reason string // a substring of the reason when not restarting
+	count  string
+}
+
+func runSteps(t *testing.T, r *restarter, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		delay, reason, ok := r.next(errExit, s.uptime)
+		if s.reason != "" {
+			if ok || !strings.Contains(reason, s.reason) {
+				t.Errorf("step %d: ok = %v, reason %q; want %q", i, ok, reason, s.reason)
+			}
+			continue
+		}
+		if !ok || delay != s.delay {
+			t.Errorf("step %d: delay %s, ok = %v (reason %q); want %s", i, delay, ok, reason, s.delay)
+		}
+		if s.count != "" && r.count() != s.count {
+			t.Errorf("step %d: count = %q, want %q", i, r.count(), s.count)
+		}
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	r := newRestarter(Restart{Policy: RestartOnFailure, Backoff: time.Second, MaxBackoff: 5 * time.Second, MinUptime: 10 * time.Second, FlapLimit: 100})
+	runSteps(t, r, []step{
+		{uptime: 0, delay: time.Second, count: "restart 1"},
+		{uptime: time.Second, delay: 2 * time.Second},
+		{uptime: 0, delay: 4 * time.Second},
+		{uptime: 0, delay: 5 * time.Second},
+		{uptime: 0, delay: 5 * time.Second},
+		// A run of MinUptime resets the backoff.
+		{uptime: 10 * time.Second, delay: time.Second},
+		{uptime: 0, delay: 2 * time.Second, count: "restart 7"},
+	})
+}
+
+func TestRestartMaxRetries(t *testing.T) {
+	r := newRestarter(Restart{Policy: RestartAlways, MaxRetries: 2, Backoff: time.Second, MinUptime: time.Second})
+	runSteps(t, r, []step{
+		{uptime: time.Minute, delay: time.Second, count: "restart 1 of 2"},
+		{uptime: time.Minute, delay: time.Second, count: "restart 2 of 2"},
+		{uptime: time.Minute, reason: "gave up after 2 restart(s)"},
+	})
+}
+
+func TestRestartFlapping(t *testing.T) {
+	r := newRestarter(Restart{Policy: RestartOnFailure, Backoff: time.Second, MinUptime: 5 * time.Second, FlapLimit: 3})
+	runSteps(t, r, []step{
+		{uptime: 0, delay: time.Second},
+		{uptime: 4 * time.Second, delay: 2 * time.Second},
+		// A long run in between starts the count again.
+		{uptime: 5 * time.Second, delay: time.Second},
+		{uptime: 0, delay: 2 * time.Second},
+		{uptime: 0, delay: 4 * time.Second},
+		{uptime: 0, reason: "flapping: exited 3 times in a row within 5s of starting"},
+	})
+}
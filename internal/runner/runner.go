@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
 	"strings"
+	"syscall"
+	"time"
 )
 
 // Run executes a command with the given environment variables and working directory.
@@ -14,24 +17,81 @@ import (
 // If cmdParts has a single element, it is still run through the shell to support
 // commands like "npm start" or piped commands.
 func Run(cmdParts []string, envVars []string, workDir string) error {
+	return RunWithRestart(cmdParts, envVars, workDir, Restart{}, nil)
+}
+
+// RunWithRestart runs a command like Run and starts it again whenever it
+// exits, as long as restart allows. notify, if set, is told about each
+// restart and about giving up. menv waits for the command when interrupted:
+// Ctrl-C reaches the command from the terminal, and SIGTERM is passed on to
+// it. Either one ends the restarts. The error of the last run is returned.
+func RunWithRestart(cmdParts []string, envVars []string, workDir string, restart Restart, notify func(msg string)) error {
 	if len(cmdParts) == 0 {
 		return fmt.Errorf("no command provided")
 	}
+	if notify == nil {
+		notify = func(string) {}
+	}
 
-	cmdStr := strings.Join(cmdParts, " ")
-	var c *exec.Cmd
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
 
-	if runtime.GOOS == "windows" {
-		c = exec.Command("cmd", "/c", cmdStr)
-	} else {
-		c = exec.Command("sh", "-c", cmdStr)
-	}
+	r := newRestarter(restart)
+	for {
+		c := shellCommand(strings.Join(cmdParts, " "))
+		c.Env = envVars
+		c.Dir = workDir
+		c.Stdin = os.Stdin
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+
+		started := time.Now()
+		if err := c.Start(); err != nil {
+			return err
+		}
+		done := make(chan error, 1)
+		go func() { done <- c.Wait() }()
 
-	c.Env = envVars
-	c.Dir = workDir
-	c.Stdin = os.Stdin
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
+		var err error
+		stopped := false
+	wait:
+		for {
+			select {
+			case err = <-done:
+				break wait
+			case sig := <-sigs:
+				stopped = true
+				if sig != os.Interrupt {
+					c.Process.Signal(sig)
+				}
+			}
+		}
+		if stopped {
+			return err
+		}
 
-	return c.Run()
+		delay, reason, ok := r.next(err, time.Since(started))
+		if !ok {
+			if reason != "" {
+				notify(fmt.Sprintf("%s; not restarting (%s)", exitStatus(err), reason))
+			}
+			return err
+		}
+		notify(fmt.Sprintf("%s; restarting in %s (%s)", exitStatus(err), delay, r.count()))
+		select {
+		case <-time.After(delay):
+		case <-sigs:
+			return err
+		}
+	}
+}
+
+// shellCommand returns a command that runs cmdStr through the shell:
+// cmd /c on Windows, sh -c elsewhere.
+func shellCommand(cmdStr string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/c", cmdStr)
+	}
+	return exec.Command("sh", "-c", cmdStr)
 }
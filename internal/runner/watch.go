@@ -0,0 +1,267 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long RunWatch waits for more changes before acting,
+// since editors often write a file in several steps.
+const watchDebounce = 200 * time.Millisecond
+
+// Watch configures RunWatch.
+type Watch struct {
+	// Paths are the files to watch.
+	Paths []string
+	// Globs are patterns of further files to watch, also matching files
+	// created after the watch started.
+	Globs []string
+	// Reload is called after a change. It returns what to restart and what
+	// to watch from then on. If it fails, the command is left running.
+	Reload func() (Reloaded, error)
+	// GracePeriod is how long the command gets to exit after SIGTERM before
+	// it is killed; DefaultGracePeriod when zero.
+	GracePeriod time.Duration
+	// Restart applies when the command exits by itself; otherwise RunWatch
+	// waits for the next change.
+	Restart Restart
+	// Notify, if set, is told about changes, restarts and exits.
+	Notify func(msg string)
+}
+
+// Reloaded is the result of Watch.Reload.
+type Reloaded struct {
+	// Command, Env and Dir are what the command is restarted with.
+	Command []string
+	Env     []string
+	Dir     string
+	// Paths and Globs replace those of the Watch.
+	Paths []string
+	Globs []string
+}
+
+// RunWatch runs a command like Run and restarts it whenever one of the
+// watched files changes: it reloads the environment, stops the command
+// gracefully (SIGTERM, then SIGKILL after the grace period) and starts it
+// again. The command runs in a process group of its own without stdin, so
+// that stopping it also stops the processes it started. Ctrl-C stops the
+// command and ends the watch, also while it is being stopped for a restart.
+// Like RunWithRestart, it returns the error of the last run, so a command
+// stopped by Ctrl-C reports the signal.
+func RunWatch(cmdParts []string, envVars []string, workDir string, w Watch) error {
+	if len(cmdParts) == 0 {
+		return fmt.Errorf("no command provided")
+	}
+	notify := w.Notify
+	if notify == nil {
+		notify = func(string) {}
+	}
+	grace := w.GracePeriod
+	if grace == 0 {
+		grace = DefaultGracePeriod
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("cannot watch files: %w", err)
+	}
+	defer watcher.Close()
+
+	// Directories are watched rather than files, so that files replaced by
+	// editors (written to a temporary file, then renamed) keep being seen.
+	files := make(map[string]bool)
+	var globs []string
+	watched := make(map[string]bool)
+	watch := func(paths, patterns []string) {
+		files, globs = make(map[string]bool), patterns
+		dirs := make(map[string]bool)
+		for _, p := range paths {
+			files[filepath.Clean(p)] = true
+			dirs[filepath.Dir(p)] = true
+		}
+		for _, g := range patterns {
+			matches, _ := filepath.Glob(g)
+			for _, m := range matches {
+				dirs[filepath.Dir(m)] = true
+			}
+			if dir := filepath.Dir(g); !strings.ContainsAny(dir, `*?[`) {
+				dirs[dir] = true
+			}
+		}
+		for dir := range dirs {
+			if watched[dir] {
+				continue
+			}
+			if err := watcher.Add(dir); err != nil {
+				notify(fmt.Sprintf("cannot watch %s: %v", dir, err))
+				continue
+			}
+			watched[dir] = true
+		}
+	}
+	matches := func(name string) bool {
+		name = filepath.Clean(name)
+		if files[name] {
+			return true
+		}
+		for _, g := range globs {
+			if ok, _ := filepath.Match(g, name); ok {
+				return true
+			}
+		}
+		return false
+	}
+	watch(w.Paths, w.Globs)
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	var (
+		done    chan error // receives the exit of the running command
+		stopCmd func(sig os.Signal) error
+		lastErr error
+		started time.Time
+		// interrupt is a signal that arrived while stopCmd was waiting.
+		interrupt os.Signal
+	)
+	start := func() error {
+		c := shellCommand(strings.Join(cmdParts, " "))
+		c.Env = envVars
+		c.Dir = workDir
+		c.Stdout = os.Stdout
+		c.Stderr = os.Stderr
+		setProcessGroup(c)
+		if err := c.Start(); err != nil {
+			return err
+		}
+		started = time.Now()
+		ch := make(chan error, 1)
+		go func() { ch <- c.Wait() }()
+		done = ch
+		stopCmd = func(sig os.Signal) error {
+			signalProcess(c, sig)
+			timeout := time.After(grace)
+			exited := ch
+			var err error
+			var poll <-chan time.Time
+			for {
+				select {
+				case err = <-exited:
+					// The rest of the process group gets the same grace
+					// period, such as a child that outlives its shell.
+					exited = nil
+					poll = time.After(0)
+					continue
+				case <-poll:
+					if !groupAlive(c) {
+						return err
+					}
+					poll = time.After(50 * time.Millisecond)
+					continue
+				case interrupt = <-sigs:
+					// Another signal kills the command at once, and ends
+					// the watch once it has exited.
+				case <-timeout:
+					notify(fmt.Sprintf("still running after %s, killing it", grace))
+				}
+				signalProcess(c, os.Kill)
+				if exited != nil {
+					err = <-ch
+				}
+				return err
+			}
+		}
+		return nil
+	}
+	// stop stops the running command, if any, and waits for it to exit.
+	stop := func(sig os.Signal) error {
+		if done == nil {
+			return lastErr
+		}
+		err := stopCmd(sig)
+		done = nil
+		return err
+	}
+
+	if err := start(); err != nil {
+		return err
+	}
+
+	r := newRestarter(w.Restart)
+	watchErrs := watcher.Errors
+	var debounce, restartTimer <-chan time.Time
+	var changed string
+	for {
+		select {
+		case lastErr = <-done:
+			done = nil
+			delay, reason, ok := r.next(lastErr, time.Since(started))
+			if ok {
+				notify(fmt.Sprintf("%s; restarting in %s (%s)", exitStatus(lastErr), delay, r.count()))
+				restartTimer = time.After(delay)
+			} else if reason != "" {
+				notify(fmt.Sprintf("%s; not restarting (%s), waiting for changes", exitStatus(lastErr), reason))
+			} else {
+				notify(fmt.Sprintf("%s; waiting for changes", exitStatus(lastErr)))
+			}
+
+		case <-restartTimer:
+			restartTimer = nil
+			if err := start(); err != nil {
+				return err
+			}
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return stop(syscall.SIGTERM)
+			}
+			if ev.Op == fsnotify.Chmod || !matches(ev.Name) {
+				continue
+			}
+			changed = ev.Name
+			debounce = time.After(watchDebounce)
+
+		case err, ok := <-watchErrs:
+			if !ok {
+				watchErrs = nil
+				continue
+			}
+			notify(fmt.Sprintf("watch error: %v", err))
+
+		case <-debounce:
+			debounce = nil
+			notify(fmt.Sprintf("%s changed, reloading", changed))
+			next, err := w.Reload()
+			if err == nil && len(next.Command) == 0 {
+				err = fmt.Errorf("no command provided")
+			}
+			if err != nil {
+				notify(fmt.Sprintf("reload failed, keeping the current process: %v", err))
+				continue
+			}
+			cmdParts, envVars, workDir = next.Command, next.Env, next.Dir
+			watch(next.Paths, next.Globs)
+			err = stop(syscall.SIGTERM)
+			restartTimer = nil
+			if interrupt != nil {
+				return err
+			}
+			r = newRestarter(w.Restart)
+			if err := start(); err != nil {
+				return err
+			}
+
+		case sig := <-sigs:
+			restartTimer = nil
+			return stop(sig)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+//go:build !windows
+
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// watchRun is a RunWatch running in the background.
+type watchRun struct {
+	done     chan error
+	messages chan string
+}
+
+func startWatch(cmd string, dir string, w Watch) *watchRun {
+	r := &watchRun{done: make(chan error, 1), messages: make(chan string, 32)}
+	w.Notify = func(msg string) { r.messages <- msg }
+	go func() { r.done <- RunWatch([]string{cmd}, os.Environ(), dir, w) }()
+	return r
+}
+
+// waitFor waits until cond holds, failing the test after a few seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// message waits for a notification containing want.
+func (r *watchRun) message(t *testing.T, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-r.messages:
+			if strings.Contains(msg, want) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no notification containing %q", want)
+		}
+	}
+}
+
+// interrupt sends SIGINT to menv, as Ctrl-C does, and returns the error of
+// RunWatch.
+func (r *watchRun) interrupt(t *testing.T) error {
+	t.Helper()
+	syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+	select {
+	case err := <-r.done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunWatch did not return after an interrupt")
+		return nil
+	}
+}
+
+func readLog(path string) string {
+	data, _ := os.ReadFile(path)
+	return string(data)
+}
+
+func TestRunWatchDebounceAndRestart(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	os.WriteFile(envFile, []byte("A=1\n"), 0644)
+	log := filepath.Join(dir, "log")
+
+	var reloads atomic.Int32
+	r := startWatch("echo first >> log; exec sleep 30", dir, Watch{
+		Paths: []string{envFile},
+		Reload: func() (Reloaded, error) {
+			reloads.Add(1)
+			return Reloaded{
+				Command: []string{"echo second $MENV_TEST_RELOADED >> log; exec sleep 30"},
+				Env:     append(os.Environ(), "MENV_TEST_RELOADED=yes"),
+				Dir:     dir,
+				Paths:   []string{envFile},
+			}, nil
+		},
+	})
+	waitFor(t, "the first run", func() bool { return readLog(log) == "first\n" })
+
+	// A burst of writes, as editors make, is a single change.
+	for i := range 3 {
+		os.WriteFile(envFile, []byte("A="+strings.Repeat("x", i)+"\n"), 0644)
+		time.Sleep(20 * time.Millisecond)
+	}
+	// Files that are not watched are ignored.
+	os.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0644)
+
+	r.message(t, ".env changed, reloading")
+	waitFor(t, "the restart", func() bool { return readLog(log) == "first\nsecond yes\n" })
+	time.Sleep(2 * watchDebounce)
+	if n := reloads.Load(); n != 1 {
+		t.Errorf("reloaded %d times, want 1", n)
+	}
+
+	// Ctrl-C stops the command, and the signal is its exit code.
+	err := r.interrupt(t)
+	if code := ExitCode(err); code != 130 {
+		t.Errorf("exit code = %d (%v), want 130", code, err)
+	}
+}
+
+func TestRunWatchInterruptWhileWaiting(t *testing.T) {
+	dir := t.TempDir()
+	r := startWatch("exit 3", dir, Watch{
+		Paths:  []string{filepath.Join(dir, ".env")},
+		Reload: func() (Reloaded, error) { return Reloaded{}, nil },
+	})
+	r.message(t, "exit status 3; waiting for changes")
+
+	// The command has already exited; its status is what is returned.
+	if code := ExitCode(r.interrupt(t)); code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+}
+
+func TestRunWatchReloadFailure(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env")
+	log := filepath.Join(dir, "log")
+	r := startWatch("echo run >> log; exec sleep 30", dir, Watch{
+		Globs:  []string{filepath.Join(dir, "*.env")},
+		Reload: func() (Reloaded, error) { return Reloaded{}, os.ErrNotExist },
+	})
+	waitFor(t, "the first run", func() bool { return readLog(log) == "run\n" })
+
+	// A file created after the start that matches a glob is seen too.
+	os.WriteFile(envFile, []byte("A=1\n"), 0644)
+	r.message(t, "reload failed, keeping the current process")
+	if got := readLog(log); got != "run\n" {
+		t.Errorf("command was restarted: %q", got)
+	}
+	r.interrupt(t)
+}